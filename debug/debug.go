@@ -9,6 +9,7 @@ import (
 	"runtime/pprof"
 	"strings"
 
+	"github.com/MrEhbr/pkg/log"
 	"github.com/alecthomas/template"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
@@ -21,6 +22,8 @@ type Server struct {
 	authToken string
 	logger    *zap.Logger
 	prefix    string
+	levels    *log.LevelController
+	recent    *log.RecentEntries
 }
 
 // Option is the functional option type for Server.
@@ -54,6 +57,22 @@ func WithPrefix(prefix string) Option {
 	}
 }
 
+// WithLevels sets the log level controller exposed on the loglevel page.
+// If it is unset, the levels of the global logger are exposed.
+func WithLevels(levels *log.LevelController) Option {
+	return func(s *Server) {
+		s.levels = levels
+	}
+}
+
+// WithRecent sets the recent log entries exposed on the logs page.
+// If it is unset, the recent entries of the global logger are exposed.
+func WithRecent(recent *log.RecentEntries) Option {
+	return func(s *Server) {
+		s.recent = recent
+	}
+}
+
 // NewServer creates a new debug server using the provided
 // functional Options.
 func NewServer(opts ...Option) *Server {
@@ -68,9 +87,9 @@ func NewServer(opts ...Option) *Server {
 	}
 
 	m := http.NewServeMux()
-	h := handler(s.authToken, s.logger)
+	h := handler(s.authToken, s.logger, s.levels, s.recent)
 	if s.authToken != "" {
-		h = authHandler(s.authToken, s.logger)
+		h = authHandler(s.authToken, s.logger, s.levels, s.recent)
 	}
 	m.Handle(s.prefix, http.StripPrefix(s.prefix, h))
 	s.serv = &http.Server{
@@ -106,7 +125,7 @@ func (s *Server) Shutdown() error {
 }
 
 // The below handler code is adapted from MIT licensed github.com/e-dard/netbug
-func handler(token string, logger *zap.Logger) http.HandlerFunc {
+func handler(token string, logger *zap.Logger, levels *log.LevelController, recent *log.RecentEntries) http.HandlerFunc {
 	info := struct {
 		Profiles []*pprof.Profile
 		Token    string
@@ -132,6 +151,10 @@ func handler(token string, logger *zap.Logger) http.HandlerFunc {
 			nhpprof.Trace(w, r)
 		case "symbol":
 			nhpprof.Symbol(w, r)
+		case "loglevel":
+			levelHandler(levels, logger).ServeHTTP(w, r)
+		case "logs":
+			recentHandler(recent, logger).ServeHTTP(w, r)
 		default:
 			// Provides access to all profiles under runtime/pprof
 			nhpprof.Handler(name).ServeHTTP(w, r)
@@ -140,10 +163,10 @@ func handler(token string, logger *zap.Logger) http.HandlerFunc {
 }
 
 // authHandler wraps the basic handler, checking the auth token.
-func authHandler(token string, logger *zap.Logger) http.HandlerFunc {
+func authHandler(token string, logger *zap.Logger, levels *log.LevelController, recent *log.RecentEntries) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.FormValue("token") == token {
-			handler(token, logger).ServeHTTP(w, r)
+		if r.URL.Query().Get("token") == token {
+			handler(token, logger, levels, recent).ServeHTTP(w, r)
 		} else {
 			http.Error(w, "Request must include valid token.", http.StatusUnauthorized)
 		}
@@ -170,6 +193,8 @@ var indexTmpl = template.Must(template.New("index").Parse(`<html>
     <table>
       <tr><td align=right><td><a href="cmdline?token={{.Token}}">cmdline</a>
       <tr><td align=right><td><a href="symbol?token={{.Token}}">symbol</a>
+      <tr><td align=right><td><a href="loglevel?token={{.Token}}">log levels</a>
+      <tr><td align=right><td><a href="logs?token={{.Token}}">recent log entries</a>
     <tr><td align=right><td><a href="goroutine?debug=2&token={{.Token}}">full goroutine stack dump</a><br>
     <table>
   </body>
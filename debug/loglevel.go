@@ -0,0 +1,104 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/MrEhbr/pkg/log"
+	"go.uber.org/zap"
+)
+
+// levelStatus is the JSON form of a log.LevelStatus.
+type levelStatus struct {
+	Level string     `json:"level"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// levelsResponse is the response of GET /loglevel.
+type levelsResponse struct {
+	levelStatus
+	Named map[string]levelStatus `json:"named"`
+}
+
+// levelRequest is the body of PUT /loglevel. An empty Name changes the
+// global level, an empty TTL keeps the level until it is changed again.
+type levelRequest struct {
+	Name  string `json:"name"`
+	Level string `json:"level"`
+	TTL   string `json:"ttl"`
+}
+
+// levelHandler shows and changes the levels of the logger controlled by
+// levels, which defaults to the global logger.
+func levelHandler(levels *log.LevelController, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctrl := levels
+		if ctrl == nil {
+			ctrl = log.L().Levels()
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var req levelRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, err := log.ParseLevel(req.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			var ttl time.Duration
+			if req.TTL != "" {
+				if ttl, err = time.ParseDuration(req.TTL); err != nil || ttl <= 0 {
+					http.Error(w, "ttl must be a positive duration", http.StatusBadRequest)
+					return
+				}
+			}
+
+			switch {
+			case req.Name == "" && ttl > 0:
+				ctrl.Elevate(level, ttl)
+			case req.Name == "":
+				ctrl.SetLevel(level)
+			case ttl > 0:
+				ctrl.ElevateNamed(req.Name, level, ttl)
+			default:
+				ctrl.SetNamedLevel(req.Name, level)
+			}
+			logger.Info("log level changed via debug server",
+				zap.String("name", req.Name),
+				zap.Stringer("level", level),
+				zap.Duration("ttl", ttl),
+			)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		global, named := ctrl.Status()
+		resp := levelsResponse{
+			levelStatus: toLevelStatus(global),
+			Named:       make(map[string]levelStatus, len(named)),
+		}
+		for name, status := range named {
+			resp.Named[name] = toLevelStatus(status)
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			logger.Error("error encoding log levels", zap.Error(err))
+		}
+	}
+}
+
+func toLevelStatus(status log.LevelStatus) levelStatus {
+	s := levelStatus{Level: status.Level.String()}
+	if !status.Until.IsZero() {
+		s.Until = &status.Until
+	}
+	return s
+}
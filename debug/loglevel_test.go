@@ -0,0 +1,67 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MrEhbr/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLevelHandler(t *testing.T) {
+	levels := log.NewLevelController(zap.NewAtomicLevelAt(zap.InfoLevel))
+	defer levels.Stop()
+	s := NewServer(WithAuthToken("secret"), WithLevels(levels))
+
+	var httpTests = []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantHeader int
+	}{
+		{"no token", "GET", "/loglevel", "", 401},
+		{"get", "GET", "/loglevel?token=secret", "", 200},
+		{"set global", "PUT", "/loglevel?token=secret", `{"level":"warn"}`, 200},
+		{"elevate named", "PUT", "/loglevel?token=secret", `{"name":"http","level":"debug","ttl":"1h"}`, 200},
+		{"unknown level", "PUT", "/loglevel?token=secret", `{"level":"warning"}`, 400},
+		{"invalid ttl", "PUT", "/loglevel?token=secret", `{"level":"debug","ttl":"soon"}`, 400},
+		{"method not allowed", "POST", "/loglevel?token=secret", "", 405},
+	}
+	for _, tt := range httpTests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			s.serv.Handler.ServeHTTP(rr, req)
+			assert.Equal(t, tt.wantHeader, rr.Code)
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	s.serv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/loglevel?token=secret", nil))
+	var resp levelsResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	assert.Equal(t, "warn", resp.Level)
+	require.Contains(t, resp.Named, "http")
+	assert.Equal(t, "debug", resp.Named["http"].Level)
+	assert.NotNil(t, resp.Named["http"].Until)
+}
+
+func TestLevelHandler_FormEncodedBody(t *testing.T) {
+	levels := log.NewLevelController(zap.NewAtomicLevelAt(zap.InfoLevel))
+	defer levels.Stop()
+	s := NewServer(WithAuthToken("secret"), WithLevels(levels))
+
+	// curl -X PUT -d '{"level":"warn"}' sends the body as a form.
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/loglevel?token=secret", strings.NewReader(`{"level":"warn"}`))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.serv.Handler.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, zap.WarnLevel, levels.Level())
+}
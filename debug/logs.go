@@ -0,0 +1,53 @@
+package debug
+
+import (
+	"net/http"
+
+	"github.com/MrEhbr/pkg/log"
+	"go.uber.org/zap"
+)
+
+// recentHandler writes the recent entries of recent, which defaults to the
+// global logger. The level, logger and q query parameters select the entries
+// at or above a level, of a logger and its descendants and containing a
+// substring. The format parameter is text or json, text by default.
+func recentHandler(recent *log.RecentEntries, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries := recent
+		if entries == nil {
+			entries = log.L().Recent()
+		}
+		if entries == nil {
+			http.Error(w, "recent log entries are not kept, see log.Config.RecentEntries", http.StatusNotFound)
+			return
+		}
+
+		filter := log.RecentFilter{
+			Logger:   r.FormValue("logger"),
+			Contains: r.FormValue("q"),
+		}
+		if level := r.FormValue("level"); level != "" {
+			lvl, err := log.ParseLevel(level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			filter.Levels = lvl
+		}
+
+		format, contentType := log.FormatConsole, "text/plain; charset=utf-8"
+		switch r.FormValue("format") {
+		case "", "text":
+		case "json":
+			format, contentType = log.FormatJSON, "application/x-ndjson"
+		default:
+			http.Error(w, "format must be text or json", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		if err := entries.Write(w, format, filter); err != nil {
+			logger.Error("error writing recent log entries", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package debug
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MrEhbr/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecentHandler(t *testing.T) {
+	cfg := log.NewTestConfig()
+	cfg.Level = "info"
+	cfg.RecentEntries = 10
+	logger, err := log.NewLogger(cfg)
+	require.NoError(t, err)
+	logger.Named("db").Zap().Debug("query users")
+	logger.Named("http").Zap().Warn("slow request")
+
+	s := NewServer(WithRecent(logger.Recent()))
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		s.serv.Handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	rr := get("/logs")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), "query users")
+	assert.Contains(t, rr.Body.String(), "slow request")
+
+	rr = get("/logs?format=json&level=warn")
+	require.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/x-ndjson", rr.Header().Get("Content-Type"))
+	assert.NotContains(t, rr.Body.String(), "query users")
+	assert.Contains(t, rr.Body.String(), `"message":"slow request"`)
+
+	rr = get("/logs?logger=db&q=users")
+	assert.Contains(t, rr.Body.String(), "query users")
+	assert.NotContains(t, rr.Body.String(), "slow request")
+
+	assert.Equal(t, http.StatusBadRequest, get("/logs?level=loud").Code)
+	assert.Equal(t, http.StatusBadRequest, get("/logs?format=xml").Code)
+}
@@ -3,18 +3,27 @@ package errors
 
 import (
 	"bytes"
+	stderrors "errors"
 	"fmt"
 	"runtime"
+	"sort"
 	"strings"
+
+	"go.uber.org/zap/zapcore"
 )
 
 // assert Error implements the error interface.
 var _ error = &Error{}
 
+// assert Error implements zapcore.ObjectMarshaler.
+var _ zapcore.ObjectMarshaler = &Error{}
+
 type Error struct {
 	Message string
 	Tags    map[string]string
 	Stack   *Stack
+	// Err is the wrapped error, if any. Its text is part of Message.
+	Err error
 }
 
 // New returns an error that formats as the given text.
@@ -32,13 +41,33 @@ func NewNamed(text string, name string) error {
 	return &Error{Message: text, Tags: map[string]string{"name": name}}
 }
 
+// TagsExtractor returns the tags of the first Error in the chain of err.
 func TagsExtractor(err error) map[string]string {
-	if e, ok := err.(*Error); ok {
+	var e *Error
+	if As(err, &e) {
 		return e.Tags
 	}
 	return nil
 }
 
+// Is reports whether any error in the chain of err matches target, see
+// the standard errors.Is.
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// As finds the first error in the chain of err that matches target, and if
+// so, sets target to that error value and returns true, see the standard
+// errors.As.
+func As(err error, target interface{}) bool {
+	return stderrors.As(err, target)
+}
+
+// Unwrap returns the error wrapped by err, or nil if there is none.
+func Unwrap(err error) error {
+	return stderrors.Unwrap(err)
+}
+
 // Error implements the error interface.
 func (e *Error) Error() string {
 	b := new(bytes.Buffer)
@@ -51,12 +80,48 @@ func (e *Error) Error() string {
 	return b.String()
 }
 
+// Unwrap returns the wrapped error, if any.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// MarshalLogObject implements zapcore.ObjectMarshaler, encoding the message,
+// the tags and the stack as separate keys.
+func (e *Error) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("message", e.Message)
+	if len(e.Tags) > 0 {
+		if err := enc.AddObject("tags", tags(e.Tags)); err != nil {
+			return err
+		}
+	}
+	if e.Stack != nil {
+		return enc.AddArray("stacktrace", e.Stack)
+	}
+	return nil
+}
+
+// tags encodes tags in key order.
+type tags map[string]string
+
+func (t tags) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		enc.AddString(k, t[k])
+	}
+	return nil
+}
+
 // WrapErr returns a Error for the given error and msg.
 func WrapErr(err error, msg, name string) error {
 	if err == nil {
 		return nil
 	}
-	e := &Error{Message: fmt.Sprintf("%s: %s", msg, err.Error()), Tags: map[string]string{"name": name}}
+	e := &Error{Message: fmt.Sprintf("%s: %s", msg, err.Error()), Tags: map[string]string{"name": name}, Err: err}
+	e.Tags = inheritTags(e.Tags, err)
 	e.populateStack()
 	return e
 }
@@ -66,7 +131,8 @@ func NameError(err error, name string) error {
 	if err == nil {
 		return nil
 	}
-	e := &Error{Message: err.Error(), Tags: map[string]string{"name": name}}
+	e := &Error{Message: err.Error(), Tags: map[string]string{"name": name}, Err: err}
+	e.Tags = inheritTags(e.Tags, err)
 	e.populateStack()
 	return e
 }
@@ -76,12 +142,14 @@ func Wrap(err error, msg, name string) error {
 	if err == nil {
 		return nil
 	}
-	e := &Error{Message: fmt.Sprintf("%s: %s", msg, err.Error())}
+	e := &Error{Message: fmt.Sprintf("%s: %s", msg, err.Error()), Err: err}
+	e.Tags = inheritTags(e.Tags, err)
 	e.populateStack()
 	return e
 }
 
-// E is a useful func for instantiating Errors.
+// E is a useful func for instantiating Errors. An error argument is wrapped,
+// the last one if there are several, and its tags are inherited.
 func E(args ...interface{}) error {
 	if len(args) == 0 {
 		panic("call to E with no arguments")
@@ -96,6 +164,8 @@ func E(args ...interface{}) error {
 		case error:
 			pad(b, ": ")
 			b.WriteString(arg.Error())
+			e.Err = arg
+			e.Tags = inheritTags(e.Tags, arg)
 		}
 	}
 	e.Message = b.String()
@@ -103,6 +173,25 @@ func E(args ...interface{}) error {
 	return e
 }
 
+// inheritTags adds to tags the tags of the first Error in the chain of err
+// which tags does not have yet, and returns it.
+func inheritTags(tags map[string]string, err error) map[string]string {
+	var inner *Error
+	if !As(err, &inner) {
+		return tags
+	}
+	for k, v := range inner.Tags {
+		if _, ok := tags[k]; ok {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string]string, len(inner.Tags))
+		}
+		tags[k] = v
+	}
+	return tags
+}
+
 // populateStack uses the runtime to populate the Error's stack struct with
 // information about the current stack.
 func (e *Error) populateStack() {
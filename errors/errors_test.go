@@ -0,0 +1,60 @@
+package errors
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrappingChain(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"WrapErr", WrapErr(sql.ErrNoRows, "get user", "storage")},
+		{"Wrap", Wrap(sql.ErrNoRows, "get user", "storage")},
+		{"NameError", NameError(sql.ErrNoRows, "storage")},
+		{"E", E("get user", sql.ErrNoRows)},
+		{"nested", Wrap(fmt.Errorf("query: %w", NameError(sql.ErrNoRows, "storage")), "get user", "api")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.True(t, Is(tt.err, sql.ErrNoRows))
+			assert.False(t, Is(tt.err, context.Canceled))
+
+			var e *Error
+			require.True(t, As(tt.err, &e))
+			assert.Same(t, tt.err, e)
+			assert.Contains(t, e.Message, sql.ErrNoRows.Error())
+		})
+	}
+}
+
+func TestInheritTags(t *testing.T) {
+	inner := NewWithTags("not found", map[string]string{"name": "storage", "code": "404"})
+
+	err := WrapErr(inner, "get user", "api")
+	assert.Equal(t, map[string]string{"name": "api", "code": "404"}, TagsExtractor(err))
+	assert.Equal(t, map[string]string{"name": "storage", "code": "404"}, inner.(*Error).Tags)
+
+	err = E("get user", fmt.Errorf("query: %w", inner))
+	assert.Equal(t, map[string]string{"name": "storage", "code": "404"}, TagsExtractor(err))
+	assert.Equal(t, map[string]string{"name": "storage", "code": "404"}, TagsExtractor(fmt.Errorf("get user: %w", inner)))
+	assert.Nil(t, TagsExtractor(sql.ErrNoRows))
+}
+
+func TestErrorIs(t *testing.T) {
+	err := Wrap(context.Canceled, "get user", "")
+	assert.Same(t, context.Canceled, Unwrap(err))
+	assert.True(t, Is(err, context.Canceled))
+
+	notFound := NewNamed("not found", "storage")
+	assert.True(t, Is(WrapErr(notFound, "get user", "api"), notFound))
+	assert.True(t, Is(fmt.Errorf("get user: %w", notFound), notFound))
+	assert.False(t, Is(NewNamed("not found", "storage"), notFound))
+	assert.False(t, Is(New("not found"), notFound))
+}
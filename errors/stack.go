@@ -1,6 +1,38 @@
 package errors
 
+import (
+	"runtime"
+
+	"go.uber.org/zap/zapcore"
+)
+
 // Stack represents errors stack trace
 type Stack struct {
 	Callers []uintptr
 }
+
+// MarshalLogArray implements zapcore.ArrayMarshaler, encoding the frames of
+// the stack as objects with the function, file and line.
+func (s *Stack) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	frames := runtime.CallersFrames(s.Callers)
+	for {
+		frame, more := frames.Next()
+		if frame.PC != 0 {
+			if err := enc.AppendObject(stackFrame(frame)); err != nil {
+				return err
+			}
+		}
+		if !more {
+			return nil
+		}
+	}
+}
+
+type stackFrame runtime.Frame
+
+func (f stackFrame) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("function", f.Function)
+	enc.AddString("file", f.File)
+	enc.AddInt("line", f.Line)
+	return nil
+}
@@ -0,0 +1,197 @@
+package log
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-logr/logr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// missingValue is logged for a key without a value.
+const missingValue = "(MISSING)"
+
+// adapterLogger is the zap logger an adapter writes to: a given logger, or
+// the global logger at the time of each call if none is given. It is
+// derived with the names, fields and caller skip of the adapter and cached
+// until the global logger changes.
+type adapterLogger struct {
+	base   *zap.Logger
+	names  []string
+	fields []zap.Field
+	skip   int
+	cached atomic.Value // *cachedLogger
+}
+
+func newAdapterLogger(z *zap.Logger, skip int) *adapterLogger {
+	return &adapterLogger{base: z, skip: skip}
+}
+
+func (a *adapterLogger) logger() *zap.Logger {
+	base := a.base
+	if base == nil {
+		base = L().zap
+	}
+	if c, ok := a.cached.Load().(*cachedLogger); ok && c.base == base {
+		return c.logger
+	}
+	logger := base.WithOptions(zap.AddCallerSkip(a.skip))
+	for _, name := range a.names {
+		logger = logger.Named(name)
+	}
+	if len(a.fields) > 0 {
+		logger = logger.With(a.fields...)
+	}
+	a.cached.Store(&cachedLogger{base: base, logger: logger})
+	return logger
+}
+
+func (a *adapterLogger) with(names []string, fields []zap.Field, skip int) *adapterLogger {
+	return &adapterLogger{
+		base:   a.base,
+		names:  append(a.names[:len(a.names):len(a.names)], names...),
+		fields: append(a.fields[:len(a.fields):len(a.fields)], fields...),
+		skip:   a.skip + skip,
+	}
+}
+
+// NewLogr returns a logr.Logger writing to z, or to the global logger if z
+// is nil. V(0) is logged at info level and all greater verbosities at debug
+// level. WithName adds a name to the zap logger, so that named levels apply.
+func NewLogr(z *zap.Logger) logr.Logger {
+	return logr.New(&logrSink{logger: newAdapterLogger(z, 1)})
+}
+
+type logrSink struct {
+	logger *adapterLogger
+}
+
+var _ logr.CallDepthLogSink = (*logrSink)(nil)
+
+func (s *logrSink) Init(info logr.RuntimeInfo) {
+	s.logger = s.logger.with(nil, nil, info.CallDepth)
+}
+
+func (s *logrSink) Enabled(level int) bool {
+	return s.logger.logger().Core().Enabled(logrLevel(level))
+}
+
+func (s *logrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	if ce := s.logger.logger().Check(logrLevel(level), msg); ce != nil {
+		ce.Write(keyvalFields(keysAndValues)...)
+	}
+}
+
+func (s *logrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	if ce := s.logger.logger().Check(zap.ErrorLevel, msg); ce != nil {
+		ce.Write(append(keyvalFields(keysAndValues), zap.Error(err))...)
+	}
+}
+
+func (s *logrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	return &logrSink{logger: s.logger.with(nil, keyvalFields(keysAndValues), 0)}
+}
+
+func (s *logrSink) WithName(name string) logr.LogSink {
+	return &logrSink{logger: s.logger.with([]string{name}, nil, 0)}
+}
+
+func (s *logrSink) WithCallDepth(depth int) logr.LogSink {
+	return &logrSink{logger: s.logger.with(nil, nil, depth)}
+}
+
+func logrLevel(level int) zapcore.Level {
+	if level > 0 {
+		return zap.DebugLevel
+	}
+	return zap.InfoLevel
+}
+
+// PrintfLogger logs formatted messages at a fixed level, for libraries
+// expecting a Printf method.
+type PrintfLogger struct {
+	logger *adapterLogger
+	level  zapcore.Level
+}
+
+// NewPrintfLogger returns a PrintfLogger writing to z at level, or to the
+// global logger if z is nil.
+func NewPrintfLogger(z *zap.Logger, level zapcore.Level) *PrintfLogger {
+	return &PrintfLogger{logger: newAdapterLogger(z, 1), level: level}
+}
+
+// Printf logs the formatted message without its trailing newline.
+func (p *PrintfLogger) Printf(format string, args ...interface{}) {
+	logger := p.logger.logger()
+	if ce := logger.Check(p.level, strings.TrimSuffix(fmt.Sprintf(format, args...), "\n")); ce != nil {
+		ce.Write()
+	}
+}
+
+// KitLogger implements the go-kit log.Logger interface. The "msg" key is
+// used as the message and the "level" key, as set by the go-kit level
+// package, as the level, info by default. The other key-value pairs become
+// fields.
+type KitLogger struct {
+	logger *adapterLogger
+}
+
+// NewKitLogger returns a KitLogger writing to z, or to the global logger if
+// z is nil.
+func NewKitLogger(z *zap.Logger) *KitLogger {
+	return &KitLogger{logger: newAdapterLogger(z, 1)}
+}
+
+// Log logs the key-value pairs.
+func (k *KitLogger) Log(keyvals ...interface{}) error {
+	level, msg := zap.InfoLevel, ""
+	rest := make([]interface{}, 0, len(keyvals))
+	for i := 0; i < len(keyvals); i += 2 {
+		if i+1 == len(keyvals) {
+			rest = append(rest, keyvals[i])
+			break
+		}
+		switch fmt.Sprint(keyvals[i]) {
+		case "msg":
+			msg = fmt.Sprint(keyvals[i+1])
+			continue
+		case "level":
+			if l, ok := levels[fmt.Sprint(keyvals[i+1])]; ok && l <= zap.ErrorLevel {
+				level = l
+				continue
+			}
+		}
+		rest = append(rest, keyvals[i], keyvals[i+1])
+	}
+	if ce := k.logger.logger().Check(level, msg); ce != nil {
+		ce.Write(keyvalFields(rest)...)
+	}
+	return nil
+}
+
+// keyvalFields converts alternating keys and values to fields.
+func keyvalFields(keyvals []interface{}) []zap.Field {
+	if len(keyvals) == 0 {
+		return nil
+	}
+	fields := make([]zap.Field, 0, (len(keyvals)+1)/2)
+	for i := 0; i < len(keyvals); i += 2 {
+		key, ok := keyvals[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyvals[i])
+		}
+		if i+1 == len(keyvals) {
+			fields = append(fields, zap.String(key, missingValue))
+			break
+		}
+		switch v := keyvals[i+1].(type) {
+		case error:
+			fields = append(fields, zap.NamedError(key, v))
+		default:
+			fields = append(fields, zap.Any(key, v))
+		}
+	}
+	return fields
+}
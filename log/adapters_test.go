@@ -0,0 +1,59 @@
+package log
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewLogr(t *testing.T) {
+	logger, logs := NewTestLogger(WithZapOptions(zap.AddCaller()))
+	logger.Levels().SetLevel(zap.InfoLevel)
+	logger.Levels().SetNamedLevel("db", zap.DebugLevel)
+
+	lr := NewLogr(logger.Zap())
+	lr.V(1).Info("hidden")
+	lr.WithName("db").WithValues("table", "users").V(1).Info("query", "rows", 3)
+	lr.Error(errors.New("boom"), "failed", "odd")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, zap.DebugLevel, entries[0].Level)
+	assert.Equal(t, "db", entries[0].LoggerName)
+	assert.Equal(t, map[string]interface{}{"table": "users", "rows": int64(3)}, entries[0].ContextMap())
+	assert.Contains(t, entries[0].Caller.File, "adapters_test.go")
+
+	assert.Equal(t, zap.ErrorLevel, entries[1].Level)
+	assert.Equal(t, map[string]interface{}{"odd": missingValue, "error": "boom"}, entries[1].ContextMap())
+}
+
+func TestPrintfLogger(t *testing.T) {
+	logger, logs := NewTestLogger()
+	defer ReplaceGlobal(logger)()
+
+	NewPrintfLogger(nil, zap.WarnLevel).Printf("retry %d\n", 2)
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zap.WarnLevel, entries[0].Level)
+	assert.Equal(t, "retry 2", entries[0].Message)
+}
+
+func TestKitLogger(t *testing.T) {
+	logger, logs := NewTestLogger()
+	kit := NewKitLogger(logger.Zap())
+
+	require.NoError(t, kit.Log("level", "error", "msg", "failed", "attempt", 3))
+	require.NoError(t, kit.Log("event", "started", "level", "fatal"))
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, zap.ErrorLevel, entries[0].Level)
+	assert.Equal(t, "failed", entries[0].Message)
+	assert.Equal(t, map[string]interface{}{"attempt": int64(3)}, entries[0].ContextMap())
+	assert.Equal(t, zap.InfoLevel, entries[1].Level)
+	assert.Equal(t, map[string]interface{}{"event": "started", "level": "fatal"}, entries[1].ContextMap())
+}
@@ -0,0 +1,247 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/MrEhbr/pkg/pool"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap/zapcore"
+)
+
+// OverflowPolicy decides what an AsyncWriter does when its queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes writes wait until there is room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the entry being written.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued entry to make room.
+	OverflowDropOldest
+)
+
+const (
+	defaultAsyncQueueSize     = 1024
+	defaultAsyncFlushInterval = time.Second
+)
+
+// AsyncConfig configures an AsyncWriter.
+type AsyncConfig struct {
+	// QueueSize is the number of entries the queue holds, 1024 if 0.
+	QueueSize int
+	// Overflow is what happens to writes when the queue is full.
+	Overflow OverflowPolicy
+	// FlushInterval is how often the underlying writer is synced, 1s if 0.
+	FlushInterval time.Duration
+}
+
+// AsyncWriter is a zapcore.WriteSyncer that queues writes in a bounded ring
+// buffer and writes them to the underlying WriteSyncer from a goroutine, so
+// that logging does not wait for slow outputs. Sync and Close wait until the
+// queue is written out.
+type AsyncWriter struct {
+	ws    zapcore.WriteSyncer
+	cfg   AsyncConfig
+	pool  *pool.BufferPool
+	flush *time.Ticker
+
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	drained  *sync.Cond
+	ring     []*bytes.Buffer
+	head     int
+	count    int
+	writing  bool
+	closed   bool
+	done     chan struct{}
+
+	metrics asyncMetrics
+}
+
+type asyncMetrics struct {
+	depth   tally.Gauge
+	dropped tally.Counter
+}
+
+// NewAsyncWriter starts writing to ws in the background. ws is locked with
+// zapcore.Lock, since it is synced periodically while entries are written.
+func NewAsyncWriter(ws zapcore.WriteSyncer, cfg AsyncConfig) *AsyncWriter {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultAsyncQueueSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultAsyncFlushInterval
+	}
+	w := &AsyncWriter{
+		ws:    zapcore.Lock(ws),
+		cfg:   cfg,
+		pool:  pool.NewBufferPool(cfg.QueueSize),
+		flush: time.NewTicker(cfg.FlushInterval),
+		ring:  make([]*bytes.Buffer, cfg.QueueSize),
+		done:  make(chan struct{}),
+	}
+	w.notEmpty = sync.NewCond(&w.mu)
+	w.notFull = sync.NewCond(&w.mu)
+	w.drained = sync.NewCond(&w.mu)
+
+	go w.run()
+	go w.runFlush()
+	return w
+}
+
+// ReportMetrics reports the queue depth as the log_queue_depth gauge and
+// the dropped entries as the log_queue_dropped counter of scope.
+func (w *AsyncWriter) ReportMetrics(scope tally.Scope) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.metrics = asyncMetrics{
+		depth:   scope.Gauge("log_queue_depth"),
+		dropped: scope.Counter("log_queue_dropped"),
+	}
+}
+
+// Write queues a copy of p. After Close it writes p directly, once the
+// queue is written out.
+func (w *AsyncWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return w.writeClosed(p)
+	}
+
+	if w.count == len(w.ring) {
+		switch w.cfg.Overflow {
+		case OverflowDropNewest:
+			w.droppedLocked()
+			w.mu.Unlock()
+			return len(p), nil
+		case OverflowDropOldest:
+			w.pool.Put(w.ring[w.head])
+			w.ring[w.head] = nil
+			w.head = (w.head + 1) % len(w.ring)
+			w.count--
+			w.droppedLocked()
+		default:
+			for w.count == len(w.ring) && !w.closed {
+				w.notFull.Wait()
+			}
+			if w.closed {
+				w.mu.Unlock()
+				return w.writeClosed(p)
+			}
+		}
+	}
+
+	b := w.pool.Get()
+	b.Write(p)
+	w.ring[(w.head+w.count)%len(w.ring)] = b
+	w.count++
+	w.notEmpty.Signal()
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+// Sync waits until the queued entries are written and syncs the underlying writer.
+func (w *AsyncWriter) Sync() error {
+	w.mu.Lock()
+	for (w.count > 0 || w.writing) && !w.closed {
+		w.drained.Wait()
+	}
+	w.mu.Unlock()
+	return w.ws.Sync()
+}
+
+// Close writes out the queue, stops the background goroutines and syncs the
+// underlying writer.
+func (w *AsyncWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.notEmpty.Broadcast()
+	w.notFull.Broadcast()
+	w.drained.Broadcast()
+	w.mu.Unlock()
+
+	w.flush.Stop()
+	<-w.done
+	return w.ws.Sync()
+}
+
+// writeClosed writes p to the underlying writer after the background
+// goroutine wrote out the queue, so that it is neither interleaved with nor
+// reordered before the queued entries.
+func (w *AsyncWriter) writeClosed(p []byte) (int, error) {
+	<-w.done
+	return w.ws.Write(p)
+}
+
+func (w *AsyncWriter) droppedLocked() {
+	if w.metrics.dropped != nil {
+		w.metrics.dropped.Inc(1)
+	}
+}
+
+// run writes out the queue in batches until the writer is closed and the
+// queue is empty.
+func (w *AsyncWriter) run() {
+	defer close(w.done)
+	batch := make([]*bytes.Buffer, 0, len(w.ring))
+	for {
+		w.mu.Lock()
+		for w.count == 0 && !w.closed {
+			w.notEmpty.Wait()
+		}
+		if w.count == 0 && w.closed {
+			w.mu.Unlock()
+			return
+		}
+		for w.count > 0 {
+			batch = append(batch, w.ring[w.head])
+			w.ring[w.head] = nil
+			w.head = (w.head + 1) % len(w.ring)
+			w.count--
+		}
+		w.writing = true
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		for i, b := range batch {
+			// Write errors have nowhere to go, like in zap's own outputs.
+			w.ws.Write(b.Bytes())
+			w.pool.Put(b)
+			batch[i] = nil
+		}
+		batch = batch[:0]
+
+		w.mu.Lock()
+		w.writing = false
+		if w.count == 0 {
+			w.drained.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// runFlush periodically syncs the underlying writer and reports the queue depth.
+func (w *AsyncWriter) runFlush() {
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-w.flush.C:
+			w.mu.Lock()
+			depth, gauge := w.count, w.metrics.depth
+			w.mu.Unlock()
+			if gauge != nil {
+				gauge.Update(float64(depth))
+			}
+			w.ws.Sync()
+		}
+	}
+}
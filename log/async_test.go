@@ -0,0 +1,165 @@
+package log
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+)
+
+// blockingWriter records writes once it is released.
+type blockingWriter struct {
+	release chan struct{}
+	mu      sync.Mutex
+	buf     bytes.Buffer
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *blockingWriter) Sync() error { return nil }
+
+func (w *blockingWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
+
+func TestAsyncWriter_Sync(t *testing.T) {
+	ws := &blockingWriter{release: make(chan struct{})}
+	close(ws.release)
+	w := NewAsyncWriter(ws, AsyncConfig{QueueSize: 4})
+	defer w.Close()
+
+	for _, s := range []string{"a", "b", "c", "d", "e", "f"} {
+		_, err := w.Write([]byte(s))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Sync())
+	assert.Equal(t, "abcdef", ws.String())
+}
+
+// unlockedWriter counts the bytes written since the last sync, without
+// locking.
+type unlockedWriter struct {
+	pending, synced int
+}
+
+func (w *unlockedWriter) Write(p []byte) (int, error) {
+	w.pending += len(p)
+	return len(p), nil
+}
+
+func (w *unlockedWriter) Sync() error {
+	w.synced += w.pending
+	w.pending = 0
+	return nil
+}
+
+func TestAsyncWriter_UnlockedWriter(t *testing.T) {
+	ws := &unlockedWriter{}
+	w := NewAsyncWriter(ws, AsyncConfig{QueueSize: 4, FlushInterval: time.Millisecond})
+
+	for i := 0; i < 200; i++ {
+		_, err := w.Write([]byte("a"))
+		require.NoError(t, err)
+		if i%50 == 0 {
+			time.Sleep(2 * time.Millisecond)
+		}
+	}
+	require.NoError(t, w.Close())
+	assert.Equal(t, 200, ws.synced)
+}
+
+func TestAsyncWriter_Overflow(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   string
+	}{
+		{OverflowDropNewest, "abc"},
+		{OverflowDropOldest, "ade"},
+	}
+	for _, tt := range tests {
+		ws := &blockingWriter{release: make(chan struct{})}
+		scope := tally.NewTestScope("", nil)
+		w := NewAsyncWriter(ws, AsyncConfig{QueueSize: 2, Overflow: tt.policy})
+		w.ReportMetrics(scope)
+
+		// The first write is taken off the queue and blocks in the writer.
+		w.Write([]byte("a"))
+		require.Eventually(t, func() bool {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			return w.writing
+		}, time.Second, time.Millisecond)
+		for _, s := range []string{"b", "c", "d", "e"} {
+			w.Write([]byte(s))
+		}
+
+		close(ws.release)
+		require.NoError(t, w.Close())
+		assert.Equal(t, tt.want, ws.String())
+		assert.EqualValues(t, 2, scope.Snapshot().Counters()["log_queue_dropped+"].Value())
+	}
+}
+
+func TestAsyncWriter_WriteAfterClose(t *testing.T) {
+	ws := &blockingWriter{release: make(chan struct{})}
+	w := NewAsyncWriter(ws, AsyncConfig{QueueSize: 4})
+
+	w.Write([]byte("a"))
+	require.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.writing
+	}, time.Second, time.Millisecond)
+	w.Write([]byte("b"))
+
+	closed := make(chan error)
+	go func() { closed <- w.Close() }()
+	require.Eventually(t, func() bool {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		return w.closed
+	}, time.Second, time.Millisecond)
+	written := make(chan struct{})
+	go func() {
+		w.Write([]byte("c"))
+		close(written)
+	}()
+
+	close(ws.release)
+	require.NoError(t, <-closed)
+	<-written
+	assert.Equal(t, "abc", ws.String())
+}
+
+func TestNewWithConfig_FlushesPrevious(t *testing.T) {
+	defer ReplaceGlobal(L())()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{path}
+	cfg.Sampling = nil
+	cfg.Async = &AsyncConfig{FlushInterval: time.Hour}
+	require.NoError(t, NewWithConfig(cfg))
+	for i := 0; i < 100; i++ {
+		Get().Info("queued")
+	}
+
+	require.NoError(t, NewWithConfig(NewTestConfig()))
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, 100, strings.Count(string(b), `"message":"queued"`))
+}
@@ -0,0 +1,528 @@
+package log
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// FormatJSON encodes entries as JSON objects.
+	FormatJSON = "json"
+	// FormatConsole encodes entries in a human-readable form with colored levels.
+	FormatConsole = "console"
+
+	// DefaultTimeFormat is the layout used for timestamps when Config.TimeFormat is empty.
+	DefaultTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+)
+
+// levels maps the accepted level names to zap levels.
+var levels = map[string]zapcore.Level{
+	"debug":  zap.DebugLevel,
+	"info":   zap.InfoLevel,
+	"warn":   zap.WarnLevel,
+	"error":  zap.ErrorLevel,
+	"dpanic": zap.DPanicLevel,
+	"panic":  zap.PanicLevel,
+	"fatal":  zap.FatalLevel,
+}
+
+// Config describes how a logger is built. Start from NewProductionConfig,
+// NewDevelopmentConfig or NewTestConfig and adjust the fields you need.
+type Config struct {
+	// Format is the output encoding, either FormatJSON or FormatConsole.
+	Format string
+	// Level is the minimum enabled level: debug, info, warn, error, dpanic, panic or fatal.
+	Level string
+	// OutputPaths is a list of URLs or file paths to write logging output to.
+	OutputPaths []string
+	// Outputs are additional destinations, each with its own format and
+	// minimum level, written to alongside OutputPaths.
+	Outputs []OutputConfig
+	// ErrorOutputPaths is a list of URLs or file paths to write internal logger errors to.
+	ErrorOutputPaths []string
+	// Rotation rotates the files among OutputPaths and Outputs. Nil leaves
+	// them growing.
+	Rotation *RotationConfig
+	// Async writes to OutputPaths in the background. Nil writes synchronously.
+	Async *AsyncConfig
+	// Sampling caps the throughput of repeated entries. Nil disables sampling.
+	Sampling *SamplingConfig
+	// RateLimit caps the throughput of entries per key. Nil disables it.
+	RateLimit *RateLimitConfig
+	// Redact removes sensitive data from entries. Nil disables redaction.
+	Redact *RedactConfig
+	// RecentEntries is the number of entries kept at all levels, regardless
+	// of Level, for inspection from the debug server. 0 disables it.
+	RecentEntries int
+	// DisableCaller stops annotating entries with the calling function's file and line.
+	DisableCaller bool
+	// StacktraceLevel is the level at and above which stacktraces are captured.
+	// Empty disables stacktraces.
+	StacktraceLevel string
+	// Development makes DPanic entries panic.
+	Development bool
+	// TimeFormat is the layout used for timestamps, DefaultTimeFormat if empty.
+	TimeFormat string
+	// Keys are the field names used for entry metadata.
+	Keys KeysConfig
+	// LevelSignals maps signals to the level they toggle the global logger to,
+	// see LevelController.WatchSignals.
+	LevelSignals map[os.Signal]string
+}
+
+// OutputConfig describes a destination of Config.Outputs.
+type OutputConfig struct {
+	// Paths is a list of URLs or file paths to write to.
+	Paths []string
+	// Format is the output encoding, Config.Format if empty.
+	Format string
+	// Level is the minimum level written, all entries enabled by the logger
+	// if empty.
+	Level string
+}
+
+// ParseOutput parses an output described as format:level:path, e.g.
+// json:error:/var/log/errors.log. Format and level may be empty.
+func ParseOutput(s string) (OutputConfig, error) {
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 || parts[2] == "" {
+		return OutputConfig{}, errors.Errorf("log: malformed output %q, expected format:level:path", s)
+	}
+	return OutputConfig{Paths: []string{parts[2]}, Format: parts[0], Level: parts[1]}, nil
+}
+
+// SamplingConfig logs the first Initial entries with the same level and
+// message in each Tick, and every Thereafter-th entry after that.
+type SamplingConfig struct {
+	Tick       time.Duration
+	Initial    int
+	Thereafter int
+}
+
+// KeysConfig sets the field names used for entry metadata.
+// An empty key omits that part of the entry.
+type KeysConfig struct {
+	Time       string
+	Level      string
+	Name       string
+	Caller     string
+	Message    string
+	Stacktrace string
+}
+
+// defaultKeys are the metadata keys shared by all predefined configs.
+func defaultKeys() KeysConfig {
+	return KeysConfig{
+		Time:       "timestamp",
+		Level:      "log_level",
+		Name:       "logger",
+		Caller:     "caller",
+		Message:    "message",
+		Stacktrace: "stacktrace",
+	}
+}
+
+// defaultLevelSignals toggles debug level on SIGUSR1.
+func defaultLevelSignals() map[os.Signal]string {
+	return map[os.Signal]string{syscall.SIGUSR1: "debug"}
+}
+
+// NewProductionConfig returns a config that writes sampled JSON at info level to stderr.
+func NewProductionConfig() Config {
+	return Config{
+		Format:           FormatJSON,
+		Level:            "info",
+		OutputPaths:      []string{"stderr"},
+		ErrorOutputPaths: []string{"stderr"},
+		Sampling: &SamplingConfig{
+			Tick:       time.Second,
+			Initial:    100,
+			Thereafter: 100,
+		},
+		TimeFormat:   DefaultTimeFormat,
+		Keys:         defaultKeys(),
+		LevelSignals: defaultLevelSignals(),
+	}
+}
+
+// NewDevelopmentConfig returns a config that writes console output at info level to stderr.
+func NewDevelopmentConfig() Config {
+	return Config{
+		Format:           FormatConsole,
+		Level:            "info",
+		OutputPaths:      []string{"stderr"},
+		ErrorOutputPaths: []string{"stderr"},
+		Development:      true,
+		TimeFormat:       DefaultTimeFormat,
+		Keys:             defaultKeys(),
+		LevelSignals:     defaultLevelSignals(),
+	}
+}
+
+// NewTestConfig returns a config at debug level without any output.
+func NewTestConfig() Config {
+	return Config{
+		Format:        FormatConsole,
+		Level:         "debug",
+		DisableCaller: true,
+		TimeFormat:    DefaultTimeFormat,
+		Keys:          defaultKeys(),
+	}
+}
+
+// Validate reports the first unknown or invalid value in the config.
+func (c Config) Validate() error {
+	switch c.Format {
+	case FormatJSON, FormatConsole:
+	default:
+		return errors.Errorf("log: unknown format %q, expected one of %s, %s", c.Format, FormatJSON, FormatConsole)
+	}
+	if _, err := ParseLevel(c.Level); err != nil {
+		return err
+	}
+	for _, path := range c.OutputPaths {
+		if err := checkSink(path); err != nil {
+			return err
+		}
+	}
+	for i, o := range c.Outputs {
+		if len(o.Paths) == 0 {
+			return errors.Errorf("log: output %d has no paths", i)
+		}
+		for _, path := range o.Paths {
+			if err := checkSink(path); err != nil {
+				return err
+			}
+		}
+		switch o.Format {
+		case "", FormatJSON, FormatConsole:
+		default:
+			return errors.Errorf("log: unknown format %q of output %d, expected one of %s, %s", o.Format, i, FormatJSON, FormatConsole)
+		}
+		if o.Level != "" {
+			if _, err := ParseLevel(o.Level); err != nil {
+				return errors.Wrapf(err, "output %d", i)
+			}
+		}
+	}
+	if c.StacktraceLevel != "" {
+		if _, err := ParseLevel(c.StacktraceLevel); err != nil {
+			return errors.Wrap(err, "stacktrace level")
+		}
+	}
+	for sig, level := range c.LevelSignals {
+		if _, err := ParseLevel(level); err != nil {
+			return errors.Wrapf(err, "level signal %s", sig)
+		}
+	}
+	if s := c.Sampling; s != nil {
+		if s.Tick <= 0 {
+			return errors.Errorf("log: sampling tick must be positive, got %s", s.Tick)
+		}
+		if s.Initial < 0 || s.Thereafter < 0 {
+			return errors.Errorf("log: sampling initial and thereafter must not be negative, got %d and %d", s.Initial, s.Thereafter)
+		}
+	}
+	if r := c.RateLimit; r != nil {
+		if r.Rate <= 0 || r.Burst < 1 {
+			return errors.Errorf("log: rate limit must have a positive rate and burst, got %g and %d", r.Rate, r.Burst)
+		}
+	}
+	if c.Redact != nil {
+		if _, err := newRedactor(*c.Redact); err != nil {
+			return err
+		}
+	}
+	if c.RecentEntries < 0 {
+		return errors.Errorf("log: recent entries must not be negative, got %d", c.RecentEntries)
+	}
+	if r := c.Rotation; r != nil {
+		if r.MaxSize < 0 || r.Every < 0 || r.MaxBackups < 0 || r.MaxAge < 0 {
+			return errors.New("log: rotation limits must not be negative")
+		}
+	}
+	if a := c.Async; a != nil {
+		if a.QueueSize < 0 || a.FlushInterval < 0 {
+			return errors.New("log: async queue size and flush interval must not be negative")
+		}
+		switch a.Overflow {
+		case OverflowBlock, OverflowDropNewest, OverflowDropOldest:
+		default:
+			return errors.Errorf("log: unknown async overflow policy %d", a.Overflow)
+		}
+	}
+	return nil
+}
+
+// Build validates the config and constructs a logger from it.
+func (c Config) Build(opts ...zap.Option) (*zap.Logger, error) {
+	l, err := c.build(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return l.zap, nil
+}
+
+// build is Build returning the Logger handle.
+func (c Config) build(opts ...zap.Option) (*Logger, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	lvl, _ := ParseLevel(c.Level)
+	levels := NewLevelController(zap.NewAtomicLevelAt(lvl))
+	drops := newDropCounter()
+
+	sink, closeOut, err := c.openOutputs(c.OutputPaths)
+	if err != nil {
+		return nil, errors.Wrap(err, "log: opening outputs")
+	}
+	closers := []func(){closeOut}
+	var closeOnce sync.Once
+	closeAll := func() {
+		closeOnce.Do(func() {
+			for _, closeFn := range closers {
+				closeFn()
+			}
+		})
+	}
+	cores := make([]zapcore.Core, 0, len(c.Outputs)+1)
+	for i, o := range c.Outputs {
+		outSink, closeFn, err := c.openOutputs(o.Paths)
+		if err != nil {
+			closeAll()
+			return nil, errors.Wrapf(err, "log: opening output %d", i)
+		}
+		closers = append(closers, closeFn)
+		format := o.Format
+		if format == "" {
+			format = c.Format
+		}
+		outCore := c.outputCore(format, outSink, levels)
+		if o.Level != "" {
+			minLevel, _ := ParseLevel(o.Level)
+			outCore = &minLevelCore{Core: outCore, min: minLevel}
+		}
+		cores = append(cores, outCore)
+	}
+	errSink, closeErr, err := zap.Open(c.ErrorOutputPaths...)
+	if err != nil {
+		closeAll()
+		return nil, errors.Wrap(err, "log: opening error outputs")
+	}
+	closers = append(closers, closeErr)
+
+	var async *AsyncWriter
+	if c.Async != nil {
+		async = NewAsyncWriter(sink, *c.Async)
+		sink = async
+	}
+
+	core := c.outputCore(c.Format, sink, levels)
+	if len(cores) > 0 {
+		core = zapcore.NewTee(append([]zapcore.Core{core}, cores...)...)
+	}
+	var r *redactor
+	if c.Redact != nil {
+		r, _ = newRedactor(*c.Redact)
+		core = newRedactCore(core, r)
+	}
+	if rl := c.RateLimit; rl != nil {
+		core = newRateLimitCore(core, *rl, drops)
+	}
+	if s := c.Sampling; s != nil {
+		core = zapcore.NewSamplerWithOptions(core, s.Tick, s.Initial, s.Thereafter,
+			zapcore.SamplerHook(drops.samplerHook))
+	}
+	outputs := core
+	core = levels.wrap(core)
+	var recent *RecentEntries
+	if c.RecentEntries > 0 {
+		recent = NewRecentEntries(c.RecentEntries)
+		core = newRecentCore(core, recent, r)
+	}
+
+	buildOpts := []zap.Option{zap.ErrorOutput(errSink)}
+	if c.Development {
+		buildOpts = append(buildOpts, zap.Development())
+	}
+	if !c.DisableCaller {
+		buildOpts = append(buildOpts, zap.AddCaller())
+	}
+	if c.StacktraceLevel != "" {
+		stackLevel, _ := ParseLevel(c.StacktraceLevel)
+		buildOpts = append(buildOpts, zap.AddStacktrace(stackLevel))
+	}
+	return &Logger{
+		zap:     zap.New(core, append(buildOpts, opts...)...),
+		levels:  levels,
+		drops:   drops,
+		async:   async,
+		recent:  recent,
+		outputs: &unleveledCore{Core: outputs},
+
+		closeOutputs: closeAll,
+	}, nil
+}
+
+// outputCore returns the core encoding entries in format into sink. JSON
+// outputs encode *errors.Error fields as objects, see errors.Error.MarshalLogObject.
+func (c Config) outputCore(format string, sink zapcore.WriteSyncer, enab zapcore.LevelEnabler) zapcore.Core {
+	core := zapcore.NewCore(c.encoder(format), sink, enab)
+	if format == FormatJSON {
+		core = newErrorObjectCore(core)
+	}
+	return core
+}
+
+// minLevelCore drops the entries below min, also when they are written
+// without being checked, e.g. by ContextWithFingersCrossed.
+type minLevelCore struct {
+	zapcore.Core
+	min zapcore.Level
+}
+
+func (c *minLevelCore) Enabled(lvl zapcore.Level) bool {
+	return lvl >= c.min && c.Core.Enabled(lvl)
+}
+
+func (c *minLevelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &minLevelCore{Core: c.Core.With(fields), min: c.min}
+}
+
+func (c *minLevelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level < c.min {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
+
+func (c *minLevelCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if ent.Level < c.min {
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// openOutputs opens paths like zap.Open does, except that files are opened
+// as RotatingFile if Rotation is set.
+func (c Config) openOutputs(paths []string) (zapcore.WriteSyncer, func(), error) {
+	if c.Rotation == nil {
+		return zap.Open(paths...)
+	}
+
+	var (
+		syncers []zapcore.WriteSyncer
+		closers []func()
+	)
+	closeAll := func() {
+		for _, closeFn := range closers {
+			closeFn()
+		}
+	}
+	for _, output := range paths {
+		if path, ok := filePath(output); ok {
+			f, err := NewRotatingFile(path, *c.Rotation)
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			syncers = append(syncers, f)
+			closers = append(closers, func() { f.Close() })
+			continue
+		}
+		sink, closeFn, err := zap.Open(output)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		syncers = append(syncers, sink)
+		closers = append(closers, closeFn)
+	}
+	return zap.CombineWriteSyncers(syncers...), closeAll, nil
+}
+
+// filePath returns the path of an output that is a file path or file URL.
+func filePath(output string) (string, bool) {
+	if output == "stdout" || output == "stderr" {
+		return "", false
+	}
+	if filepath.IsAbs(output) {
+		return output, true
+	}
+	u, err := url.Parse(output)
+	if err != nil {
+		return "", false
+	}
+	switch u.Scheme {
+	case "":
+		return output, true
+	case "file":
+		return u.Path, true
+	}
+	return "", false
+}
+
+// encoder returns the entry encoder for format.
+func (c Config) encoder(format string) zapcore.Encoder {
+	timeFormat := c.TimeFormat
+	if timeFormat == "" {
+		timeFormat = DefaultTimeFormat
+	}
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        c.Keys.Time,
+		LevelKey:       c.Keys.Level,
+		NameKey:        c.Keys.Name,
+		CallerKey:      c.Keys.Caller,
+		MessageKey:     c.Keys.Message,
+		StacktraceKey:  c.Keys.Stacktrace,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.TimeEncoderOfLayout(timeFormat),
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	if format == FormatConsole {
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoderConfig.EncodeDuration = zapcore.StringDurationEncoder
+		return zapcore.NewConsoleEncoder(encoderConfig)
+	}
+	return zapcore.NewJSONEncoder(encoderConfig)
+}
+
+// ParseLevel converts a level name to a zap level. Unlike zapcore it rejects
+// anything but the exact names, so typos such as "warning" are reported.
+func ParseLevel(s string) (zapcore.Level, error) {
+	if lvl, ok := levels[strings.ToLower(s)]; ok {
+		return lvl, nil
+	}
+	return zap.InfoLevel, errors.Errorf("log: unknown level %q, expected one of %s", s, levelNames())
+}
+
+// levelSignals parses the levels of LevelSignals.
+func (c Config) levelSignals() map[os.Signal]zapcore.Level {
+	signals := make(map[os.Signal]zapcore.Level, len(c.LevelSignals))
+	for sig, level := range c.LevelSignals {
+		signals[sig], _ = ParseLevel(level)
+	}
+	return signals
+}
+
+// levelNames lists the accepted level names from lowest to highest.
+func levelNames() string {
+	names := make([]string, 0, len(levels))
+	for name := range levels {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return levels[names[i]] < levels[names[j]] })
+	return strings.Join(names, ", ")
+}
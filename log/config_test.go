@@ -0,0 +1,180 @@
+package log
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// closedSinks counts the closed sinks of the closecheck scheme.
+var closedSinks int32
+
+type closeCheckSink struct{}
+
+func (closeCheckSink) Write(p []byte) (int, error) { return len(p), nil }
+
+func (closeCheckSink) Sync() error { return nil }
+
+func (closeCheckSink) Close() error {
+	atomic.AddInt32(&closedSinks, 1)
+	return nil
+}
+
+func init() {
+	if err := zap.RegisterSink("closecheck", func(*url.URL) (zap.Sink, error) {
+		return closeCheckSink{}, nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{"production", func(*Config) {}, ""},
+		{"upper case level", func(c *Config) { c.Level = "DEBUG" }, ""},
+		{"unknown level", func(c *Config) { c.Level = "warning" }, `unknown level "warning"`},
+		{"unknown format", func(c *Config) { c.Format = "xml" }, `unknown format "xml"`},
+		{"unknown stacktrace level", func(c *Config) { c.StacktraceLevel = "loud" }, `stacktrace level: log: unknown level "loud"`},
+		{"zero sampling tick", func(c *Config) { c.Sampling.Tick = 0 }, "sampling tick must be positive"},
+		{"output without paths", func(c *Config) { c.Outputs = []OutputConfig{{Format: FormatJSON}} }, "output 0 has no paths"},
+		{"unknown output level", func(c *Config) { c.Outputs = []OutputConfig{{Paths: []string{"stdout"}, Level: "loud"}} }, `output 0: log: unknown level "loud"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := NewProductionConfig()
+			tt.mutate(&cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	lvl, err := ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, zap.WarnLevel, lvl)
+
+	_, err = ParseLevel("warning")
+	require.EqualError(t, err, `log: unknown level "warning", expected one of debug, info, warn, error, dpanic, panic, fatal`)
+}
+
+func TestConfig_Build(t *testing.T) {
+	cfg := NewTestConfig()
+	cfg.Level = "warn"
+	logger, err := cfg.Build()
+	require.NoError(t, err)
+	assert.False(t, logger.Core().Enabled(zap.InfoLevel))
+	assert.True(t, logger.Core().Enabled(zap.WarnLevel))
+
+	cfg.Level = "verbose"
+	_, err = cfg.Build()
+	require.Error(t, err)
+}
+
+func TestParseOutput(t *testing.T) {
+	output, err := ParseOutput("json:error:file:///var/log/errors.log")
+	require.NoError(t, err)
+	assert.Equal(t, OutputConfig{Paths: []string{"file:///var/log/errors.log"}, Format: FormatJSON, Level: "error"}, output)
+
+	output, err = ParseOutput("::stdout")
+	require.NoError(t, err)
+	assert.Equal(t, OutputConfig{Paths: []string{"stdout"}}, output)
+
+	_, err = ParseOutput("json:/var/log/errors.log")
+	assert.Error(t, err)
+}
+
+func TestConfig_Build_Outputs(t *testing.T) {
+	dir := t.TempDir()
+	cfg := NewDevelopmentConfig()
+	cfg.OutputPaths = []string{filepath.Join(dir, "console.log")}
+	cfg.Outputs = []OutputConfig{{Paths: []string{filepath.Join(dir, "errors.log")}, Format: FormatJSON, Level: "error"}}
+	cfg.DisableCaller = true
+	logger, err := cfg.Build()
+	require.NoError(t, err)
+
+	logger.Info("started")
+	logger.Error("failed")
+	require.NoError(t, logger.Sync())
+
+	console, err := os.ReadFile(filepath.Join(dir, "console.log"))
+	require.NoError(t, err)
+	assert.Contains(t, string(console), "started")
+	assert.Contains(t, string(console), "failed")
+
+	errs, err := os.ReadFile(filepath.Join(dir, "errors.log"))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(errs)), "\n")
+	require.Len(t, lines, 1)
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "error", entry["log_level"])
+	assert.Equal(t, "failed", entry["message"])
+}
+
+func TestNewWithConfig_ClosesPrevious(t *testing.T) {
+	defer ReplaceGlobal(L())()
+
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{filepath.Join(t.TempDir(), "app.log")}
+	cfg.Rotation = &RotationConfig{MaxSize: 1 << 20, ReopenOnSighup: true}
+	cfg.Async = &AsyncConfig{}
+	require.NoError(t, NewWithConfig(cfg))
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		require.NoError(t, NewWithConfig(cfg))
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+	require.NoError(t, Close())
+}
+
+func TestLogger_Close_ErrorOutputs(t *testing.T) {
+	cfg := NewTestConfig()
+	cfg.ErrorOutputPaths = []string{"closecheck://errors"}
+	before := atomic.LoadInt32(&closedSinks)
+	logger, err := NewLogger(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, logger.Close())
+	assert.Equal(t, before+1, atomic.LoadInt32(&closedSinks))
+}
+
+func TestNewWithConfig_KeepsReplaced(t *testing.T) {
+	defer ReplaceGlobal(L())()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{path}
+	cfg.Sampling = nil
+	cfg.Rotation = &RotationConfig{}
+	logger, err := NewLogger(cfg)
+	require.NoError(t, err)
+	defer logger.Close()
+
+	ReplaceGlobal(logger)
+	require.NoError(t, NewWithConfig(NewTestConfig()))
+	require.NoError(t, NewWithConfig(NewTestConfig()))
+	logger.Zap().Info("still open")
+	require.NoError(t, logger.Zap().Sync())
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "still open")
+}
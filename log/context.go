@@ -2,6 +2,9 @@ package log
 
 import (
 	"context"
+	"sync"
+	"sync/atomic"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -14,41 +17,175 @@ const (
 	loggerKey key = iota
 	// fieldsKey is the context key for the Fields.
 	fieldsKey
+	// requestIDKey is the context key for the request ID set by Middleware.
+	requestIDKey
+	// crossedKey is the context key for the buffer of ContextWithFingersCrossed.
+	crossedKey
 )
 
-// NewContext creates a new context the given contextual fields
+// NewContext creates a new context with the given contextual fields and the
+// logger of ctx, falling back to the global logger.
 func NewContext(ctx context.Context, fields ...zapcore.Field) context.Context {
-	return context.WithValue(ctx, loggerKey, FromContext(ctx).With(fields...))
+	if _, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return contextWithBoundFields(ctx, fields)
+	}
+	return L().NewContext(ctx, fields...)
 }
 
-// FromContext returns a logger from the given context
+// FromContext returns a logger from the given context, falling back to the
+// global logger, see ReplaceGlobal.
 func FromContext(ctx context.Context) *zap.Logger {
-	if ctx == nil {
-		return wrappedLogger.zap
-	}
-	if ctxLogger, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
-		return ctxLogger.With(FieldsFromContext(ctx)...)
-	}
-	return wrappedLogger.zap.With(FieldsFromContext(ctx)...)
+	return L().FromContext(ctx)
 }
 
-// FieldsFromContext retrieves the Fields from ctx.
+// FieldsFromContext retrieves the Fields from ctx, including the ones added
+// with NewContext. A field overrides earlier fields with the same key. The
+// returned slice must not be modified, appending to it does not affect ctx.
 func FieldsFromContext(ctx context.Context) []zap.Field {
-	if fields, ok := ctx.Value(fieldsKey).([]zap.Field); ok {
-		return fields
+	if node := fieldsFromContext(ctx); node != nil {
+		return node.allFields()
 	}
 	return []zap.Field{}
 }
 
 // ContextWithFields set fields in a new context based on ctx, and returns this
-// context. Any Fields defined in ctx will be overriden.
+// context. Any Fields defined in ctx will be overriden, except the ones added
+// with NewContext.
 func ContextWithFields(ctx context.Context, fields ...zap.Field) context.Context {
-	return context.WithValue(ctx, fieldsKey, fields)
+	parent := fieldsFromContext(ctx)
+	for parent != nil && !parent.bound {
+		parent = parent.parent
+	}
+	return context.WithValue(ctx, fieldsKey, &fieldNode{parent: parent, fields: fields})
 }
 
 // AddFieldToCtx add set of fields in a new context based on ctx, and returns this context.
+// The fields override fields of ctx with the same keys.
 func AddFieldToCtx(ctx context.Context, fields ...zap.Field) context.Context {
-	ctxFields := FieldsFromContext(ctx)
-	ctxFields = append(ctxFields, fields...)
-	return ContextWithFields(ctx, ctxFields...)
+	return context.WithValue(ctx, fieldsKey, &fieldNode{parent: fieldsFromContext(ctx), fields: fields})
+}
+
+// RemoveFieldFromCtx removes the fields with the given keys from ctx,
+// including the ones added with NewContext, and returns the new context.
+func RemoveFieldFromCtx(ctx context.Context, keys ...string) context.Context {
+	return context.WithValue(ctx, fieldsKey, &fieldNode{parent: fieldsFromContext(ctx), removed: keys})
+}
+
+// contextWithBoundFields adds fields to ctx that ContextWithFields keeps.
+func contextWithBoundFields(ctx context.Context, fields []zap.Field) context.Context {
+	return context.WithValue(ctx, fieldsKey, &fieldNode{parent: fieldsFromContext(ctx), fields: fields, bound: true})
+}
+
+func fieldsFromContext(ctx context.Context) *fieldNode {
+	node, _ := ctx.Value(fieldsKey).(*fieldNode)
+	return node
+}
+
+// fieldNode is an immutable list of the fields added to or removed from a
+// context, linked to the fields of its parent context. It caches the logger
+// derived from it, so that its fields are encoded once per base logger
+// instead of on every FromContext.
+type fieldNode struct {
+	parent  *fieldNode
+	fields  []zap.Field
+	removed []string
+	// bound marks fields added with NewContext, which ContextWithFields keeps.
+	bound  bool
+	cached atomic.Value // *cachedLogger
+	traced atomic.Value // *tracedNode, see traceNode
+
+	// all are the fields of the parent followed by fields, with the latest
+	// field of each key only and no spare capacity so that appending to them
+	// copies. own are the fields of the node without duplicates, derive
+	// reports whether they override or remove none of the parent's fields,
+	// so that the logger can be derived from the parent's. They are built on
+	// first use.
+	allOnce sync.Once
+	all     []zap.Field
+	own     []zap.Field
+	derive  bool
+}
+
+type cachedLogger struct {
+	base   *zap.Logger
+	logger *zap.Logger
+}
+
+// allFields returns the fields of the node and its ancestors, oldest first.
+func (n *fieldNode) allFields() []zap.Field {
+	n.allOnce.Do(n.build)
+	return n.all
+}
+
+func (n *fieldNode) build() {
+	n.own = uniqueFields(n.fields)
+	n.derive = len(n.own) == len(n.fields)
+	if n.parent == nil {
+		n.all = n.own[:len(n.own):len(n.own)]
+		return
+	}
+
+	parent := n.parent.allFields()
+	all := make([]zap.Field, 0, len(parent)+len(n.own))
+	for _, f := range parent {
+		if hasKey(n.own, f.Key) || contains(n.removed, f.Key) {
+			n.derive = false
+			continue
+		}
+		all = append(all, f)
+	}
+	n.all = append(all, n.own...)
+}
+
+// logger returns base with the fields of the node, deriving it from the
+// cached logger of the parent when the node only adds new keys.
+func (n *fieldNode) logger(base *zap.Logger) *zap.Logger {
+	if c, ok := n.cached.Load().(*cachedLogger); ok && c.base == base {
+		return c.logger
+	}
+	logger, fields := base, n.allFields()
+	if n.parent != nil && n.derive {
+		logger, fields = n.parent.logger(base), n.own
+	}
+	if len(fields) > 0 {
+		logger = logger.With(fields...)
+	}
+	n.cached.Store(&cachedLogger{base: base, logger: logger})
+	return logger
+}
+
+// uniqueFields returns fields with only the last field of each key, or
+// fields itself if the keys are unique.
+func uniqueFields(fields []zap.Field) []zap.Field {
+	for i := range fields {
+		if !hasKey(fields[i+1:], fields[i].Key) {
+			continue
+		}
+		unique := make([]zap.Field, 0, len(fields)-1)
+		for j, f := range fields {
+			if !hasKey(fields[j+1:], f.Key) {
+				unique = append(unique, f)
+			}
+		}
+		return unique
+	}
+	return fields
+}
+
+func hasKey(fields []zap.Field, key string) bool {
+	for _, f := range fields {
+		if f.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
 }
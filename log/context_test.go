@@ -38,3 +38,140 @@ func Test_FromContext_Fields(t *testing.T) {
 
 	require.Contains(t, logs.TakeAll()[0].Context, field, "Fields does not contain expected field")
 }
+
+func Test_Logger_FromContext_Independent(t *testing.T) {
+	t.Parallel()
+	logger, logs := NewTestLogger(WithName("independent"))
+
+	ctx := logger.NewContext(context.Background(), zap.String("request_id", "42"))
+	logger.FromContext(ctx).Info("test")
+	FromContext(context.Background()).Info("global")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "independent", entries[0].LoggerName)
+	assert.Contains(t, entries[0].Context, zap.String("request_id", "42"))
+}
+
+func Test_Logger_NewContext_Replaces(t *testing.T) {
+	outer, outerLogs := NewTestLogger()
+	inner, innerLogs := NewTestLogger()
+
+	ctx := outer.NewContext(context.Background(), zap.String("request_id", "42"))
+	ctx = inner.NewContext(ctx, zap.String("handler", "test"))
+	FromContext(ctx).Info("inner")
+	ctx = NewContext(ctx, zap.String("user_id", "1"))
+	FromContext(ctx).Info("kept")
+
+	assert.Zero(t, outerLogs.Len())
+	entries := innerLogs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, []zap.Field{zap.String("request_id", "42"), zap.String("handler", "test"), zap.String("user_id", "1")}, entries[1].Context)
+}
+
+func Test_ReplaceGlobal(t *testing.T) {
+	logger, logs := NewTestLogger()
+	restore := ReplaceGlobal(logger)
+	FromContext(context.Background()).Info("test")
+	restore()
+	FromContext(context.Background()).Info("ignored")
+
+	require.Equal(t, 1, logs.Len())
+	assert.NotSame(t, logger, L())
+}
+
+func Test_AddFieldToCtx_Siblings(t *testing.T) {
+	parent := ContextWithFields(context.Background(), zap.Int("a", 1), zap.Int("b", 2), zap.Int("c", 3))
+	parent = AddFieldToCtx(parent, zap.Int("d", 4))
+	left := AddFieldToCtx(parent, zap.String("side", "left"))
+	right := AddFieldToCtx(parent, zap.String("side", "right"))
+
+	assert.Equal(t, zap.String("side", "left"), FieldsFromContext(left)[4])
+	assert.Equal(t, zap.String("side", "right"), FieldsFromContext(right)[4])
+	assert.Len(t, FieldsFromContext(parent), 4)
+}
+
+func Test_FromContext_Cached(t *testing.T) {
+	logger, logs := NewTestLogger()
+	ctx := AddFieldToCtx(context.Background(), zap.String("request_id", "42"))
+	ctx = AddFieldToCtx(ctx, zap.String("user", "bob"))
+
+	assert.Same(t, logger.FromContext(ctx), logger.FromContext(ctx))
+	logger.FromContext(ctx).Info("test")
+
+	other, _ := NewTestLogger()
+	assert.NotSame(t, logger.FromContext(ctx), other.FromContext(ctx))
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, []zap.Field{zap.String("request_id", "42"), zap.String("user", "bob")}, entries[0].Context)
+}
+
+func Test_FromContext_LastWriteWins(t *testing.T) {
+	logger, logs := NewTestLogger()
+	ctx := logger.NewContext(context.Background(), zap.String("user_id", "1"), zap.String("request_id", "42"))
+	ctx = AddFieldToCtx(ctx, zap.String("method", "GET"))
+	ctx = AddFieldToCtx(ctx, zap.String("user_id", "2"), zap.String("user_id", "3"))
+	logger.FromContext(ctx).Info("override")
+
+	ctx = ContextWithFields(ctx, zap.String("path", "/"))
+	logger.FromContext(ctx).Info("replace")
+
+	ctx = RemoveFieldFromCtx(ctx, "user_id", "path")
+	logger.FromContext(ctx).Info("remove")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 3)
+	assert.Equal(t, []zap.Field{
+		zap.String("request_id", "42"),
+		zap.String("method", "GET"),
+		zap.String("user_id", "3"),
+	}, entries[0].Context)
+	assert.Equal(t, []zap.Field{
+		zap.String("user_id", "1"),
+		zap.String("request_id", "42"),
+		zap.String("path", "/"),
+	}, entries[1].Context)
+	assert.Equal(t, []zap.Field{zap.String("request_id", "42")}, entries[2].Context)
+	assert.Equal(t, entries[2].Context, FieldsFromContext(ctx))
+}
+
+func BenchmarkAddFieldToCtx(b *testing.B) {
+	ctx := ContextWithFields(context.Background(), zap.String("request_id", "42"), zap.String("method", "GET"))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		AddFieldToCtx(ctx, zap.String("user", "bob"))
+	}
+}
+
+func BenchmarkFromContext(b *testing.B) {
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = nil
+	cfg.Sampling = nil
+	logger, err := NewLogger(cfg)
+	require.NoError(b, err)
+	ctx := ContextWithFields(context.Background(), zap.String("request_id", "42"), zap.String("method", "GET"))
+	ctx = AddFieldToCtx(ctx, zap.String("user", "bob"))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.FromContext(ctx).Info("test")
+	}
+}
+
+func BenchmarkFromContext_Trace(b *testing.B) {
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = nil
+	cfg.Sampling = nil
+	logger, err := NewLogger(cfg)
+	require.NoError(b, err)
+	ctx, err := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(b, err)
+	ctx = ContextWithFields(ctx, zap.String("request_id", "42"), zap.String("method", "GET"))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.FromContext(ctx).Info("test")
+	}
+}
@@ -8,20 +8,55 @@ import (
 
 type TagsExtractor func(err error) map[string]string
 
+// ErrorMetricsOption configures the core built by NewErrorMetricsCore.
+type ErrorMetricsOption func(*core)
+
+// ErrorMetricsLevel counts entries at level and above instead of ErrorLevel and above.
+func ErrorMetricsLevel(level zapcore.Level) ErrorMetricsOption {
+	return func(c *core) {
+		c.level = level
+	}
+}
+
+// ErrorMetricsTagFields uses the values of string fields with the given keys
+// as tags, e.g. fields added to the context with AddFieldToCtx.
+func ErrorMetricsTagFields(keys ...string) ErrorMetricsOption {
+	return func(c *core) {
+		c.tagFields = make(map[string]struct{}, len(keys))
+		for _, key := range keys {
+			c.tagFields[key] = struct{}{}
+		}
+	}
+}
+
 type core struct {
 	reporter   tally.Scope
 	core       zapcore.Core
 	extractor  TagsExtractor
 	metricName string
+	level      zapcore.Level
+	tagFields  map[string]struct{}
+	// tags are collected from the fields added with With.
+	tags map[string]string
 }
 
-func NewErrorMetricsCore(originalCore zapcore.Core, extractor TagsExtractor, metricName string, reporter tally.Scope) zapcore.Core {
-	return &core{
+// NewErrorMetricsCore wraps originalCore to count entries at error level and
+// above into the metricName counter of reporter. The counter is tagged with
+// the entry level, the tags extracted from error fields and the values of
+// the tag fields, see ErrorMetricsTagFields. Tags from fields added with With
+// are kept, fields of the entry itself take precedence.
+func NewErrorMetricsCore(originalCore zapcore.Core, extractor TagsExtractor, metricName string, reporter tally.Scope, opts ...ErrorMetricsOption) zapcore.Core {
+	c := &core{
 		reporter:   reporter,
 		core:       originalCore,
 		extractor:  extractor,
 		metricName: metricName,
+		level:      zap.ErrorLevel,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
 }
 
 func (c *core) Enabled(level zapcore.Level) bool {
@@ -29,30 +64,68 @@ func (c *core) Enabled(level zapcore.Level) bool {
 }
 
 func (c *core) With(fields []zapcore.Field) zapcore.Core {
-	return c.core.With(fields)
+	clone := *c
+	clone.core = c.core.With(fields)
+	clone.tags = c.collectTags(c.tags, fields)
+	return &clone
 }
 
+// Check lets the wrapped core decide whether to log the entry, and counts
+// it only if it is logged.
 func (c *core) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
-	if c.Enabled(ent.Level) {
-		return ce.AddCore(ent, c)
+	downstream := c.core.Check(ent, ce)
+	if downstream != ce && ent.Level >= c.level {
+		return downstream.AddCore(ent, c)
 	}
-	return ce
+	return downstream
 }
 
+// Write counts the entry. The wrapped core registered itself in Check and
+// writes the entry on its own.
 func (c *core) Write(entry zapcore.Entry, fields []zapcore.Field) error {
-	if entry.Level == zap.ErrorLevel {
-		var tags map[string]string
-		for _, field := range fields {
-			if field.Type == zapcore.ErrorType {
-				tags = c.extractor(field.Interface.(error))
-				break
-			}
-		}
-		c.reporter.Tagged(tags).Counter(c.metricName).Inc(1)
+	collected := c.collectTags(c.tags, fields)
+	tags := make(map[string]string, len(collected)+1)
+	for k, v := range collected {
+		tags[k] = v
 	}
-	return c.core.Write(entry, fields)
+	tags["level"] = entry.Level.String()
+	c.reporter.Tagged(tags).Counter(c.metricName).Inc(1)
+	return nil
 }
 
 func (c *core) Sync() error {
 	return c.core.Sync()
 }
+
+// collectTags returns a copy of tags merged with the tags found in fields,
+// or tags itself if fields have none.
+func (c *core) collectTags(tags map[string]string, fields []zapcore.Field) map[string]string {
+	merged, copied := tags, false
+	set := func(key, value string) {
+		if !copied {
+			merged = make(map[string]string, len(tags)+1)
+			for k, v := range tags {
+				merged[k] = v
+			}
+			copied = true
+		}
+		merged[key] = value
+	}
+	for _, field := range fields {
+		switch {
+		case field.Type == zapcore.ErrorType && c.extractor != nil:
+			err, ok := field.Interface.(error)
+			if !ok || err == nil {
+				continue
+			}
+			for k, v := range c.extractor(err) {
+				set(k, v)
+			}
+		case field.Type == zapcore.StringType:
+			if _, ok := c.tagFields[field.Key]; ok {
+				set(field.Key, field.String)
+			}
+		}
+	}
+	return merged
+}
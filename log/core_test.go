@@ -0,0 +1,55 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/MrEhbr/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestErrorMetricsCore(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	observed, logs := observer.New(zap.DebugLevel)
+	logger := zap.New(NewErrorMetricsCore(observed, errors.TagsExtractor, "errors", scope,
+		ErrorMetricsTagFields("service"),
+	))
+
+	derived := logger.With(zap.String("service", "billing"), zap.Error(errors.NewNamed("boom", "db")))
+	derived.Error("failed")
+	derived.Warn("not counted")
+	logger.DPanic("panicked", zap.Error(errors.NewWithTags("boom", map[string]string{"name": "cache"})))
+	logger.Error("untagged", zap.Error(errors.NewWithTags("boom", nil)))
+
+	assert.Equal(t, 4, logs.Len(), "wrapped core must keep writing entries")
+	assert.EqualValues(t, 1, counterValue(scope, "errors", map[string]string{"service": "billing", "name": "db", "level": "error"}))
+	assert.EqualValues(t, 1, counterValue(scope, "errors", map[string]string{"name": "cache", "level": "dpanic"}))
+	assert.EqualValues(t, 3, counterValue(scope, "errors", nil))
+}
+
+func TestErrorMetricsCore_NilExtractor(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	observed, _ := observer.New(zap.DebugLevel)
+	logger := zap.New(NewErrorMetricsCore(observed, nil, "errors", scope, ErrorMetricsLevel(zap.WarnLevel)))
+
+	logger.Info("ignored")
+	logger.Warn("warned", zap.Error(errors.New("boom")))
+
+	assert.EqualValues(t, 1, counterValue(scope, "errors", map[string]string{"level": "warn"}))
+}
+
+func TestErrorMetricsCore_Tee(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	all, allLogs := observer.New(zap.DebugLevel)
+	fatal, fatalLogs := observer.New(zap.FatalLevel)
+	logger := zap.New(zapcore.NewTee(all, NewErrorMetricsCore(fatal, nil, "errors", scope)))
+
+	logger.Error("rejected by the wrapped core")
+
+	assert.Equal(t, 1, allLogs.Len())
+	assert.Zero(t, fatalLogs.Len())
+	assert.EqualValues(t, 0, counterValue(scope, "errors", nil))
+}
@@ -0,0 +1,165 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// defaultCrossedSize is the buffer size of ContextWithFingersCrossed if 0.
+const defaultCrossedSize = 100
+
+// ContextWithFingersCrossed returns a copy of ctx in which the loggers from
+// FromContext keep the entries they would not write because of their level,
+// up to size entries, 100 if 0, dropping the oldest. When an entry is logged
+// at error level or above in the context, the kept entries are written
+// before it, and all entries are written from then on. If nothing goes wrong
+// the kept entries are discarded with the context.
+func ContextWithFingersCrossed(ctx context.Context, size int) context.Context {
+	if size <= 0 {
+		size = defaultCrossedSize
+	}
+	return context.WithValue(ctx, crossedKey, &crossedBuffer{size: size})
+}
+
+func crossedFromContext(ctx context.Context) *crossedBuffer {
+	buf, _ := ctx.Value(crossedKey).(*crossedBuffer)
+	return buf
+}
+
+type crossedEntry struct {
+	ent    zapcore.Entry
+	fields []zapcore.Field
+}
+
+// crossedBuffer keeps the entries of a context until an error is logged.
+type crossedBuffer struct {
+	size   int
+	cached atomic.Value // *cachedLogger
+
+	mu        sync.Mutex
+	entries   []crossedEntry
+	triggered bool
+}
+
+// logger returns base writing through the buffer into outputs, cached
+// until base changes.
+func (b *crossedBuffer) logger(base *zap.Logger, outputs zapcore.Core) *zap.Logger {
+	if c, ok := b.cached.Load().(*cachedLogger); ok && c.base == base {
+		return c.logger
+	}
+	logger := base.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		wrap := func(core zapcore.Core) zapcore.Core {
+			return &crossedCore{Core: core, outputs: outputs, buf: b}
+		}
+		if rc, ok := core.(*recentCore); ok {
+			return rc.wrap(wrap)
+		}
+		return wrap(core)
+	}))
+	b.cached.Store(&cachedLogger{base: base, logger: logger})
+	return logger
+}
+
+// add keeps an entry, unless the buffer was triggered.
+func (b *crossedBuffer) add(e crossedEntry) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.triggered {
+		return false
+	}
+	if len(b.entries) == b.size {
+		copy(b.entries, b.entries[1:])
+		b.entries = b.entries[:b.size-1]
+	}
+	b.entries = append(b.entries, e)
+	return true
+}
+
+// trigger writes the kept entries to outputs, once.
+func (b *crossedBuffer) trigger(outputs zapcore.Core) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.triggered {
+		return
+	}
+	b.triggered = true
+	for _, e := range b.entries {
+		writeThrough(outputs, e.ent, e.fields)
+	}
+	b.entries = nil
+}
+
+// crossedCore keeps the entries the wrapped core does not write in a
+// crossedBuffer and writes them into outputs, which skips the level checks,
+// once the buffer is triggered. outputs is checked like the logger's core,
+// so that the wrappers of Logger.wrapOutputs count the entries.
+type crossedCore struct {
+	zapcore.Core
+	outputs zapcore.Core
+	buf     *crossedBuffer
+	context []zapcore.Field
+}
+
+// Enabled reports true, entries at all levels are kept.
+func (c *crossedCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *crossedCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	clone.context = append(c.context[:len(c.context):len(c.context)], fields...)
+	return &clone
+}
+
+func (c *crossedCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level >= zap.ErrorLevel {
+		c.buf.trigger(c.outputs)
+		return c.Core.Check(ent, ce)
+	}
+	if downstream := c.Core.Check(ent, ce); downstream != ce {
+		return downstream
+	}
+	return ce.AddCore(ent, c)
+}
+
+// Write keeps the entry, or writes it if the buffer was triggered.
+func (c *crossedCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	all := make([]zapcore.Field, 0, len(c.context)+len(fields))
+	all = append(append(all, c.context...), fields...)
+	if !c.buf.add(crossedEntry{ent: ent, fields: all}) {
+		writeThrough(c.outputs, ent, all)
+	}
+	return nil
+}
+
+// writeThrough checks the entry against core and writes it with every core
+// that accepted it. Write errors have nowhere to go, the entry was logged
+// successfully already.
+func writeThrough(core zapcore.Core, ent zapcore.Entry, fields []zapcore.Field) {
+	if ce := core.Check(ent, nil); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// unleveledCore accepts entries at all levels and writes them into the
+// wrapped core without its level checks.
+type unleveledCore struct {
+	zapcore.Core
+}
+
+func (c *unleveledCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *unleveledCore) With(fields []zapcore.Field) zapcore.Core {
+	return &unleveledCore{Core: c.Core.With(fields)}
+}
+
+func (c *unleveledCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(ent, c)
+}
@@ -0,0 +1,101 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestContextWithFingersCrossed(t *testing.T) {
+	logger, logs := NewTestLogger()
+	logger.Levels().SetLevel(zap.InfoLevel)
+
+	ok := ContextWithFingersCrossed(logger.NewContext(context.Background(), zap.String("request_id", "1")), 0)
+	logger.FromContext(ok).Debug("kept")
+	logger.FromContext(ok).Info("written")
+	require.Len(t, logs.TakeAll(), 1)
+
+	failed := ContextWithFingersCrossed(logger.NewContext(context.Background(), zap.String("request_id", "2")), 2)
+	for i := 0; i < 3; i++ {
+		logger.FromContext(failed).Debug(fmt.Sprintf("step %d", i), zap.Int("step", i))
+	}
+	logger.FromContext(context.Background()).Debug("other context")
+	assert.Zero(t, logs.Len())
+
+	logger.FromContext(failed).Error("failed")
+	logger.FromContext(failed).Debug("after")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 4)
+	assert.Equal(t, "step 1", entries[0].Message)
+	assert.Equal(t, zap.DebugLevel, entries[0].Level)
+	assert.Equal(t, []zap.Field{zap.String("request_id", "2"), zap.Int("step", 1)}, entries[0].Context)
+	assert.Equal(t, "step 2", entries[1].Message)
+	assert.Equal(t, "failed", entries[2].Message)
+	assert.Equal(t, "after", entries[3].Message)
+}
+
+func TestContextWithFingersCrossed_Outputs(t *testing.T) {
+	cfg := NewTestConfig()
+	cfg.Level = "info"
+	cfg.RecentEntries = 10
+	path := filepath.Join(t.TempDir(), "errors.log")
+	cfg.Outputs = []OutputConfig{{Paths: []string{path}, Format: FormatJSON, Level: "error"}}
+	logger, err := NewLogger(cfg)
+	require.NoError(t, err)
+
+	ctx := ContextWithFingersCrossed(context.Background(), 0)
+	logger.FromContext(ctx).Debug("kept")
+	assert.Len(t, logger.Recent().Entries(RecentFilter{}), 1)
+	logger.FromContext(ctx).Error("failed")
+	assert.Len(t, logger.Recent().Entries(RecentFilter{}), 2)
+
+	require.NoError(t, logger.Zap().Sync())
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(written), "kept")
+	assert.Contains(t, string(written), "failed")
+}
+
+func TestContextWithFingersCrossed_Metrics(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	logger, logs := NewTestLogger(WithMetrics(scope))
+	logger.Levels().SetLevel(zap.InfoLevel)
+
+	ctx := ContextWithFingersCrossed(context.Background(), 0)
+	logger.FromContext(ctx).Debug("kept")
+	assert.EqualValues(t, 0, counterValue(scope, "debug_count", nil))
+	logger.FromContext(ctx).Error("failed")
+	logger.FromContext(ctx).Debug("after")
+
+	assert.Equal(t, 3, logs.Len())
+	assert.EqualValues(t, 2, counterValue(scope, "debug_count", nil))
+	assert.EqualValues(t, 1, counterValue(scope, "error_count", nil))
+}
+
+func TestCrossedCore_Tee(t *testing.T) {
+	all, allLogs := observer.New(zap.DebugLevel)
+	info, infoLogs := observer.New(zap.InfoLevel)
+	buf := &crossedBuffer{size: 10}
+	logger := zap.New(zapcore.NewTee(all, &crossedCore{Core: info, outputs: &unleveledCore{Core: info}, buf: buf}))
+
+	logger.Debug("kept")
+	assert.Equal(t, 1, allLogs.Len())
+	assert.Zero(t, infoLogs.Len())
+	assert.Len(t, buf.entries, 1)
+
+	logger.Error("failed")
+	entries := infoLogs.All()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "kept", entries[0].Message)
+	assert.Equal(t, "failed", entries[1].Message)
+}
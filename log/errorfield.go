@@ -0,0 +1,79 @@
+package log
+
+import (
+	"github.com/MrEhbr/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// errorObjectCore encodes error fields holding an *errors.Error as objects
+// with separate message, tags and stacktrace keys instead of the string of
+// the error, which embeds the stack.
+type errorObjectCore struct {
+	zapcore.Core
+}
+
+func newErrorObjectCore(core zapcore.Core) zapcore.Core {
+	return &errorObjectCore{Core: core}
+}
+
+func (c *errorObjectCore) With(fields []zapcore.Field) zapcore.Core {
+	return &errorObjectCore{Core: c.Core.With(errorObjects(fields))}
+}
+
+func (c *errorObjectCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *errorObjectCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(ent, errorObjects(fields))
+}
+
+// errorObjects returns fields with *errors.Error fields replaced by objects,
+// or fields itself if there are none.
+func errorObjects(fields []zapcore.Field) []zapcore.Field {
+	replaced, copied := fields, false
+	for i, f := range fields {
+		if f.Type != zapcore.ErrorType {
+			continue
+		}
+		e, ok := f.Interface.(*errors.Error)
+		if !ok || e == nil {
+			continue
+		}
+		if !copied {
+			replaced = make([]zapcore.Field, len(fields))
+			copy(replaced, fields)
+			copied = true
+		}
+		replaced[i] = zap.Object(f.Key, e)
+	}
+	return replaced
+}
+
+// errorValue returns a copy of v with the message and tags redacted if v is
+// an *errors.Error with sensitive data, so that it is still encoded as an
+// object by errorObjectCore.
+func (r *redactor) errorValue(v interface{}) (error, bool) {
+	e, ok := v.(*errors.Error)
+	if !ok || e == nil {
+		return nil, false
+	}
+	redacted := &errors.Error{Message: r.maskString(e.Message), Stack: e.Stack, Err: e.Err}
+	changed := redacted.Message != e.Message
+	if len(e.Tags) > 0 {
+		redacted.Tags = make(map[string]string, len(e.Tags))
+		for k, v := range e.Tags {
+			masked := r.maskString(v)
+			if r.redactKey(k) {
+				masked = r.mask
+			}
+			changed = changed || masked != v
+			redacted.Tags[k] = masked
+		}
+	}
+	return redacted, changed
+}
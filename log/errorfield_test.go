@@ -0,0 +1,81 @@
+package log
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MrEhbr/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestErrorObjectCore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{path}
+	cfg.Sampling = nil
+	logger, err := cfg.Build()
+	require.NoError(t, err)
+
+	e := errors.NameError(errors.New("no rows"), "storage")
+	logger.With(zap.NamedError("cause", e)).Error("query failed", zap.Error(e), zap.NamedError("plain", os.ErrNotExist))
+	require.NoError(t, logger.Sync())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var entry struct {
+		Plain string `json:"plain"`
+		Cause struct {
+			Message string `json:"message"`
+		} `json:"cause"`
+		Error struct {
+			Message    string            `json:"message"`
+			Tags       map[string]string `json:"tags"`
+			Stacktrace []struct {
+				Function string `json:"function"`
+				File     string `json:"file"`
+				Line     int    `json:"line"`
+			} `json:"stacktrace"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(b, &entry), string(b))
+	assert.Equal(t, "file does not exist", entry.Plain)
+	assert.Equal(t, "no rows", entry.Cause.Message)
+	assert.Equal(t, "no rows", entry.Error.Message)
+	assert.Equal(t, map[string]string{"name": "storage"}, entry.Error.Tags)
+	require.NotEmpty(t, entry.Error.Stacktrace)
+	assert.Contains(t, entry.Error.Stacktrace[0].Function, "TestErrorObjectCore")
+	assert.Equal(t, "errorfield_test.go", filepath.Base(entry.Error.Stacktrace[0].File))
+	assert.NotZero(t, entry.Error.Stacktrace[0].Line)
+}
+
+func TestErrorObjectCore_Redact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.log")
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = []string{path}
+	cfg.Sampling = nil
+	cfg.Redact = NewRedactConfig()
+	logger, err := cfg.Build()
+	require.NoError(t, err)
+
+	inner := errors.NewWithTags("login bob@example.com", map[string]string{"token": "abc"})
+	logger.Error("login failed", zap.Error(errors.WrapErr(inner, "auth", "api")))
+	require.NoError(t, logger.Sync())
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var entry struct {
+		Error struct {
+			Message    string            `json:"message"`
+			Tags       map[string]string `json:"tags"`
+			Stacktrace []interface{}     `json:"stacktrace"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(b, &entry), string(b))
+	assert.Equal(t, "auth: login [REDACTED]", entry.Error.Message)
+	assert.Equal(t, map[string]string{"name": "api", "token": "[REDACTED]"}, entry.Error.Tags)
+	assert.NotEmpty(t, entry.Error.Stacktrace)
+}
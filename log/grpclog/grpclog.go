@@ -0,0 +1,295 @@
+// Package grpclog provides gRPC interceptors that attach a request-scoped
+// logger to the call context and log the completion of each call.
+package grpclog
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MrEhbr/pkg/errors"
+	"github.com/MrEhbr/pkg/log"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MethodKey is the field key of the full method name.
+	MethodKey = "grpc_method"
+	// PeerKey is the field key of the peer address.
+	PeerKey = "grpc_peer"
+	// CodeKey is the field key of the status code of a finished call.
+	CodeKey = "grpc_code"
+	// DurationKey is the field key of the duration of a finished call.
+	DurationKey = "grpc_duration"
+)
+
+// Option configures the interceptors.
+type Option func(*options)
+
+type options struct {
+	logger     func() *log.Logger
+	levels     func(codes.Code) zapcore.Level
+	skip       map[string]struct{}
+	metrics    tally.Scope
+	metricName string
+	metricOpts []log.ErrorMetricsOption
+	// metered is the last logger wrapped with the error metrics.
+	metered atomic.Value // *meteredLogger
+}
+
+type meteredLogger struct {
+	base, logger *log.Logger
+}
+
+// WithLogger sets the logger to use. By default it is the global logger at
+// the time of each call.
+func WithLogger(l *log.Logger) Option {
+	return func(o *options) {
+		o.logger = func() *log.Logger { return l }
+	}
+}
+
+// WithCodeLevels sets the level at which calls finishing with a code are
+// logged. By default OK is logged at info level and every other code at
+// error level.
+func WithCodeLevels(fn func(codes.Code) zapcore.Level) Option {
+	return func(o *options) {
+		o.levels = fn
+	}
+}
+
+// WithSkipMethods disables the completion log of calls to the given full
+// method names, e.g. /grpc.health.v1.Health/Check. Failed calls are still
+// logged.
+func WithSkipMethods(methods ...string) Option {
+	return func(o *options) {
+		for _, method := range methods {
+			o.skip[method] = struct{}{}
+		}
+	}
+}
+
+// WithErrorMetrics counts failed calls, tagged with the tags extracted by
+// errors.TagsExtractor and the status code, into the metricName counter of
+// scope, like log.LoggerWithErrorMetrics. Use it only if the logger does
+// not report error metrics already, otherwise failures are counted twice.
+func WithErrorMetrics(scope tally.Scope, metricName string, opts ...log.ErrorMetricsOption) Option {
+	return func(o *options) {
+		o.metrics = scope
+		o.metricName = metricName
+		o.metricOpts = opts
+	}
+}
+
+// DefaultCodeLevel logs OK at info level and every other code at error level.
+func DefaultCodeLevel(code codes.Code) zapcore.Level {
+	if code == codes.OK {
+		return zap.InfoLevel
+	}
+	return zap.ErrorLevel
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		logger: log.L,
+		levels: DefaultCodeLevel,
+		skip:   make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.metrics != nil {
+		o.metricOpts = append([]log.ErrorMetricsOption{log.ErrorMetricsTagFields(CodeKey)}, o.metricOpts...)
+	}
+	return o
+}
+
+// resolve returns the logger of a call, with the error metrics if any. The
+// wrapped logger is cached until the logger changes.
+func (o *options) resolve() *log.Logger {
+	l := o.logger()
+	if o.metrics == nil {
+		return l
+	}
+	if m, ok := o.metered.Load().(*meteredLogger); ok && m.base == l {
+		return m.logger
+	}
+	m := &meteredLogger{base: l, logger: l.WithErrorMetrics(o.metrics, errors.TagsExtractor, o.metricName, o.metricOpts...)}
+	o.metered.Store(m)
+	return m.logger
+}
+
+// UnaryServerInterceptor returns a server interceptor that stores a logger
+// with the method and peer in the call context, see log.FromContext, and
+// logs the completion of each call with its status code and duration.
+func UnaryServerInterceptor(opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		l := o.resolve()
+		ctx = l.NewContext(ctx, callFields(ctx, info.FullMethod)...)
+		resp, err := handler(ctx, req)
+		o.finish(l.FromContext(ctx), "request", info.FullMethod, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor is the streaming variant of UnaryServerInterceptor.
+func StreamServerInterceptor(opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		l := o.resolve()
+		ctx := l.NewContext(ss.Context(), callFields(ss.Context(), info.FullMethod)...)
+		err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+		o.finish(l.FromContext(ctx), "request", info.FullMethod, start, err)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns a client interceptor that logs the
+// completion of each call with the method, peer, status code and duration,
+// using the logger of the call context.
+func UnaryClientInterceptor(opts ...Option) grpc.UnaryClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		start := time.Now()
+		var p peer.Peer
+		err := invoker(ctx, method, req, reply, cc, append(callOpts, grpc.Peer(&p))...)
+		o.finish(o.clientLogger(ctx, method, &p), "call", method, start, err)
+		return err
+	}
+}
+
+// StreamClientInterceptor is the streaming variant of UnaryClientInterceptor.
+// The call is finished when receiving returns an error or io.EOF, or the
+// response of a call without server streaming, sending returns an error
+// other than io.EOF, which leaves the status to receiving, or the call
+// context is done.
+func StreamClientInterceptor(opts ...Option) grpc.StreamClientInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		p := &peer.Peer{}
+		cs, err := streamer(ctx, desc, cc, method, append(callOpts, grpc.Peer(p))...)
+		if err != nil {
+			o.finish(o.clientLogger(ctx, method, p), "call", method, start, err)
+			return nil, err
+		}
+		s := &clientStream{ClientStream: cs, unary: !desc.ServerStreams, done: make(chan struct{}), finish: func(err error) {
+			o.finish(o.clientLogger(ctx, method, p), "call", method, start, err)
+		}}
+		if ctx.Done() != nil {
+			go s.watch(ctx)
+		}
+		return s, nil
+	}
+}
+
+func (o *options) clientLogger(ctx context.Context, method string, p *peer.Peer) *zap.Logger {
+	fields := []zap.Field{zap.String(MethodKey, method)}
+	if p.Addr != nil {
+		fields = append(fields, zap.String(PeerKey, p.Addr.String()))
+	}
+	return o.resolve().FromContext(ctx).With(fields...)
+}
+
+// finish logs the completion of a call at the level of its status code.
+func (o *options) finish(logger *zap.Logger, msg, method string, start time.Time, err error) {
+	code := status.Code(err)
+	if _, ok := o.skip[method]; ok && err == nil {
+		return
+	}
+	ce := logger.Check(o.levels(code), msg)
+	if ce == nil {
+		return
+	}
+	fields := []zap.Field{
+		zap.String(CodeKey, code.String()),
+		zap.Duration(DurationKey, time.Since(start)),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+	ce.Write(fields...)
+}
+
+func callFields(ctx context.Context, method string) []zap.Field {
+	fields := []zap.Field{zap.String(MethodKey, method)}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String(PeerKey, p.Addr.String()))
+	}
+	return fields
+}
+
+// serverStream overrides the context of a grpc.ServerStream.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// clientStream calls finish once the stream ends.
+type clientStream struct {
+	grpc.ClientStream
+	// unary marks calls with a single response, which ends them.
+	unary  bool
+	once   sync.Once
+	done   chan struct{}
+	finish func(error)
+}
+
+func (s *clientStream) SendMsg(m interface{}) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil && err != io.EOF {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) CloseSend() error {
+	err := s.ClientStream.CloseSend()
+	if err != nil {
+		s.end(err)
+	}
+	return err
+}
+
+func (s *clientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	switch {
+	case err == io.EOF, err == nil && s.unary:
+		s.end(nil)
+	case err != nil:
+		s.end(err)
+	}
+	return err
+}
+
+// watch ends the stream when ctx is done before the stream ends otherwise.
+func (s *clientStream) watch(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		s.end(status.FromContextError(ctx.Err()).Err())
+	case <-s.done:
+	}
+}
+
+// end calls finish with err, once.
+func (s *clientStream) end(err error) {
+	s.once.Do(func() {
+		close(s.done)
+		s.finish(err)
+	})
+}
@@ -0,0 +1,204 @@
+package grpclog
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/MrEhbr/pkg/errors"
+	"github.com/MrEhbr/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestInterceptors(t *testing.T) {
+	logger, logs := log.NewTestLogger()
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(WithLogger(logger))),
+		grpc.StreamInterceptor(StreamServerInterceptor(WithLogger(logger))),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("ok", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(WithLogger(logger))),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "ok"})
+	require.NoError(t, err)
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "request", entries[0].Message)
+	assert.Equal(t, "call", entries[1].Message)
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		assert.Equal(t, zap.InfoLevel, entry.Level)
+		assert.Equal(t, "/grpc.health.v1.Health/Check", fields[MethodKey])
+		assert.Equal(t, "OK", fields[CodeKey])
+		assert.Contains(t, fields, PeerKey)
+		assert.Contains(t, fields, DurationKey)
+	}
+
+	_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	entries = logs.TakeAll()
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.Equal(t, zap.ErrorLevel, entry.Level)
+		assert.Equal(t, "NotFound", entry.ContextMap()[CodeKey])
+	}
+}
+
+func TestUnaryServerInterceptor_ErrorMetrics(t *testing.T) {
+	logger, logs := log.NewTestLogger()
+	scope := tally.NewTestScope("", nil)
+	interceptor := UnaryServerInterceptor(
+		WithLogger(logger),
+		WithErrorMetrics(scope, "errors"),
+		WithSkipMethods("/svc/Ok"),
+	)
+
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		log.FromContext(ctx).Debug("unused")
+		if req == nil {
+			return nil, errors.NewNamed("boom", "storage")
+		}
+		return req, nil
+	}
+	_, err := interceptor(context.Background(), "req", &grpc.UnaryServerInfo{FullMethod: "/svc/Ok"}, handler)
+	require.NoError(t, err)
+	assert.Empty(t, logs.FilterMessage("request").All())
+
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Fail"}, handler)
+	require.Error(t, err)
+	entries := logs.FilterMessage("request").All()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "/svc/Fail", entries[0].ContextMap()[MethodKey])
+
+	counters := scope.Snapshot().Counters()
+	require.Len(t, counters, 1)
+	for _, c := range counters {
+		assert.Equal(t, "errors", c.Name())
+		assert.Equal(t, int64(1), c.Value())
+		assert.Equal(t, map[string]string{"name": "storage", CodeKey: "Unknown", "level": "error"}, c.Tags())
+	}
+}
+
+func TestUnaryServerInterceptor_ErrorMetricsGlobal(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	interceptor := UnaryServerInterceptor(WithErrorMetrics(scope, "errors"))
+
+	logger, logs := log.NewTestLogger()
+	defer log.ReplaceGlobal(logger)()
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.NewNamed("boom", "storage")
+	}
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Fail"}, handler)
+	require.Error(t, err)
+	assert.Len(t, logs.FilterMessage("request").All(), 1)
+	assert.Len(t, scope.Snapshot().Counters(), 1)
+}
+
+func TestUnaryServerInterceptor_ErrorMetricsStoredLogger(t *testing.T) {
+	logger, logs := log.NewTestLogger()
+	scope := tally.NewTestScope("", nil)
+	interceptor := UnaryServerInterceptor(WithLogger(logger), WithErrorMetrics(scope, "errors"))
+
+	other, otherLogs := log.NewTestLogger()
+	ctx := other.NewContext(context.Background())
+	handler := func(context.Context, interface{}) (interface{}, error) {
+		return nil, errors.NewNamed("boom", "storage")
+	}
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/svc/Fail"}, handler)
+	require.Error(t, err)
+	assert.Zero(t, otherLogs.Len())
+	assert.Len(t, logs.FilterMessage("request").All(), 1)
+	counters := scope.Snapshot().Counters()
+	require.Len(t, counters, 1)
+	for _, c := range counters {
+		assert.Equal(t, int64(1), c.Value())
+	}
+}
+
+// stubClientStream fails sending with sendErr and receives a response.
+type stubClientStream struct {
+	grpc.ClientStream
+	sendErr error
+}
+
+func (s *stubClientStream) SendMsg(interface{}) error { return s.sendErr }
+
+func (s *stubClientStream) RecvMsg(interface{}) error { return nil }
+
+func TestStreamClientInterceptor_Finish(t *testing.T) {
+	logger, logs := log.NewTestLogger()
+	interceptor := StreamClientInterceptor(WithLogger(logger))
+	open := func(ctx context.Context, sendErr error) grpc.ClientStream {
+		streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+			return &stubClientStream{sendErr: sendErr}, nil
+		}
+		cs, err := interceptor(ctx, &grpc.StreamDesc{}, nil, "/svc/Stream", streamer)
+		require.NoError(t, err)
+		return cs
+	}
+
+	cs := open(context.Background(), status.Error(codes.Unavailable, "gone"))
+	require.Error(t, cs.SendMsg("req"))
+	require.Error(t, cs.SendMsg("req"))
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Unavailable", entries[0].ContextMap()[CodeKey])
+
+	ctx, cancel := context.WithCancel(context.Background())
+	open(ctx, nil)
+	cancel()
+	require.Eventually(t, func() bool { return logs.Len() == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, "Canceled", logs.TakeAll()[0].ContextMap()[CodeKey])
+}
+
+func TestStreamClientInterceptor_ClientStreaming(t *testing.T) {
+	logger, logs := log.NewTestLogger()
+	interceptor := StreamClientInterceptor(WithLogger(logger))
+	streamer := func(context.Context, *grpc.StreamDesc, *grpc.ClientConn, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+		return &stubClientStream{}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cs, err := interceptor(ctx, &grpc.StreamDesc{ClientStreams: true}, nil, "/svc/Upload", streamer)
+	require.NoError(t, err)
+	require.NoError(t, cs.SendMsg("req"))
+	require.NoError(t, cs.RecvMsg(nil))
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zap.InfoLevel, entries[0].Level)
+	assert.Equal(t, "OK", entries[0].ContextMap()[CodeKey])
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	assert.Zero(t, logs.Len())
+
+	cs, err = interceptor(context.Background(), &grpc.StreamDesc{ServerStreams: true}, nil, "/svc/Watch", streamer)
+	require.NoError(t, err)
+	require.NoError(t, cs.RecvMsg(nil))
+	assert.Zero(t, logs.Len())
+}
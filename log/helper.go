@@ -1,6 +1,9 @@
 package log
 
 import (
+	"time"
+
+	"github.com/pkg/errors"
 	"gopkg.in/urfave/cli.v2"
 	"gopkg.in/urfave/cli.v2/altsrc"
 )
@@ -21,25 +24,235 @@ func Flags() []cli.Flag {
 				Value:   "json",
 				EnvVars: []string{"LOG_FORMAT"},
 			}),
+		altsrc.NewStringSliceFlag(
+			&cli.StringSliceFlag{
+				Name:    "log_output",
+				Aliases: []string{"log.output"},
+				Value:   cli.NewStringSlice("stderr"),
+				Usage:   "stdout, stderr, file paths or URLs to write logs to, e.g. syslog+udp://localhost:514 or ndjson+tcp://localhost:5170",
+				EnvVars: []string{"LOG_OUTPUT"},
+			}),
+		altsrc.NewStringSliceFlag(
+			&cli.StringSliceFlag{
+				Name:    "log_tee",
+				Aliases: []string{"log.tee"},
+				Usage:   "additional outputs as format:level:path, e.g. json:error:/var/log/errors.log, empty format and level default to log_format and all levels",
+				EnvVars: []string{"LOG_TEE"},
+			}),
+		altsrc.NewIntFlag(
+			&cli.IntFlag{
+				Name:    "log_rotate_max_size",
+				Aliases: []string{"log.rotate.max_size"},
+				Usage:   "size in megabytes after which log files are rotated, 0 for no limit",
+				EnvVars: []string{"LOG_ROTATE_MAX_SIZE"},
+			}),
+		altsrc.NewDurationFlag(
+			&cli.DurationFlag{
+				Name:    "log_rotate_every",
+				Aliases: []string{"log.rotate.every"},
+				Usage:   "age after which log files are rotated, 0 for no limit",
+				EnvVars: []string{"LOG_ROTATE_EVERY"},
+			}),
+		altsrc.NewIntFlag(
+			&cli.IntFlag{
+				Name:    "log_rotate_max_backups",
+				Aliases: []string{"log.rotate.max_backups"},
+				Usage:   "number of rotated log files to keep, 0 to keep all of them",
+				EnvVars: []string{"LOG_ROTATE_MAX_BACKUPS"},
+			}),
+		altsrc.NewDurationFlag(
+			&cli.DurationFlag{
+				Name:    "log_rotate_max_age",
+				Aliases: []string{"log.rotate.max_age"},
+				Usage:   "age after which rotated log files are removed, 0 to keep them",
+				EnvVars: []string{"LOG_ROTATE_MAX_AGE"},
+			}),
+		altsrc.NewBoolFlag(
+			&cli.BoolFlag{
+				Name:    "log_rotate_compress",
+				Aliases: []string{"log.rotate.compress"},
+				Usage:   "gzip rotated log files",
+				EnvVars: []string{"LOG_ROTATE_COMPRESS"},
+			}),
+		altsrc.NewBoolFlag(
+			&cli.BoolFlag{
+				Name:    "log_reopen_on_sighup",
+				Aliases: []string{"log.reopen_on_sighup"},
+				Usage:   "reopen log files on SIGHUP, for rotation by logrotate",
+				EnvVars: []string{"LOG_REOPEN_ON_SIGHUP"},
+			}),
+		altsrc.NewBoolFlag(
+			&cli.BoolFlag{
+				Name:    "log_async",
+				Aliases: []string{"log.async"},
+				Usage:   "write logs from a background goroutine through a bounded queue",
+				EnvVars: []string{"LOG_ASYNC"},
+			}),
+		altsrc.NewIntFlag(
+			&cli.IntFlag{
+				Name:    "log_async_queue_size",
+				Aliases: []string{"log.async.queue_size"},
+				Value:   defaultAsyncQueueSize,
+				Usage:   "number of entries queued by log_async",
+				EnvVars: []string{"LOG_ASYNC_QUEUE_SIZE"},
+			}),
+		altsrc.NewStringFlag(
+			&cli.StringFlag{
+				Name:    "log_async_overflow",
+				Aliases: []string{"log.async.overflow"},
+				Value:   "block",
+				Usage:   "what log_async does when the queue is full: block, drop_newest or drop_oldest",
+				EnvVars: []string{"LOG_ASYNC_OVERFLOW"},
+			}),
+		altsrc.NewIntFlag(
+			&cli.IntFlag{
+				Name:    "log_sampling_initial",
+				Aliases: []string{"log.sampling.initial"},
+				Usage:   "entries with the same level and message logged each second before sampling, 0 with log_sampling_thereafter 0 disables sampling",
+				EnvVars: []string{"LOG_SAMPLING_INITIAL"},
+			}),
+		altsrc.NewIntFlag(
+			&cli.IntFlag{
+				Name:    "log_sampling_thereafter",
+				Aliases: []string{"log.sampling.thereafter"},
+				Usage:   "log every Nth entry with the same level and message after log_sampling_initial",
+				EnvVars: []string{"LOG_SAMPLING_THEREAFTER"},
+			}),
+		altsrc.NewFloat64Flag(
+			&cli.Float64Flag{
+				Name:    "log_rate_limit",
+				Aliases: []string{"log.rate_limit"},
+				Usage:   "entries per second allowed per message or log_rate_limit_key value, 0 disables rate limiting",
+				EnvVars: []string{"LOG_RATE_LIMIT"},
+			}),
+		altsrc.NewIntFlag(
+			&cli.IntFlag{
+				Name:    "log_rate_burst",
+				Aliases: []string{"log.rate_burst"},
+				Value:   1,
+				Usage:   "entries allowed at once per message or log_rate_limit_key value",
+				EnvVars: []string{"LOG_RATE_BURST"},
+			}),
+		altsrc.NewStringFlag(
+			&cli.StringFlag{
+				Name:    "log_rate_limit_key",
+				Aliases: []string{"log.rate_limit_key"},
+				Usage:   "field whose value keys the rate limit instead of the message",
+				EnvVars: []string{"LOG_RATE_LIMIT_KEY"},
+			}),
+		altsrc.NewIntFlag(
+			&cli.IntFlag{
+				Name:    "log_recent_entries",
+				Aliases: []string{"log.recent_entries"},
+				Usage:   "number of recent entries kept at all levels for the debug server, 0 disables it",
+				EnvVars: []string{"LOG_RECENT_ENTRIES"},
+			}),
+		altsrc.NewBoolFlag(
+			&cli.BoolFlag{
+				Name:    "log_redact",
+				Aliases: []string{"log.redact"},
+				Usage:   "redact credentials, credit card numbers, bearer tokens and emails",
+				EnvVars: []string{"LOG_REDACT"},
+			}),
 	}
 }
 
-// Setup helps with setting up logger
+// Setup helps with setting up logger. It starts from the profile of the env
+// flag, see RegisterProfile, and overrides it with the log flags that were
+// set.
 func Setup(c *cli.Context) error {
-	if c.String("env") == "prod" || c.String("env") == "int" || c.String("env") == "sandbox" {
-		if err := New(c.String("log_format"), c.String("log_level")); err != nil {
-			return err
+	cfg, err := ProfileConfig(c.String("env"))
+	if err != nil {
+		return cli.Exit(err.Error(), 1)
+	}
+	if err := applyFlags(c, &cfg); err != nil {
+		return err
+	}
+	return NewWithConfig(cfg)
+}
+
+// overflowPolicies maps the values of the log_async_overflow flag.
+var overflowPolicies = map[string]OverflowPolicy{
+	"block":       OverflowBlock,
+	"drop_newest": OverflowDropNewest,
+	"drop_oldest": OverflowDropOldest,
+}
+
+// applyFlags overrides cfg with the flags that were set.
+func applyFlags(c *cli.Context, cfg *Config) error {
+	if c.IsSet("log_level") {
+		cfg.Level = c.String("log_level")
+	}
+	if c.IsSet("log_format") {
+		cfg.Format = c.String("log_format")
+	}
+	if c.IsSet("log_output") {
+		cfg.OutputPaths = c.StringSlice("log_output")
+	}
+	if c.IsSet("log_tee") {
+		cfg.Outputs = nil
+		for _, spec := range c.StringSlice("log_tee") {
+			output, err := ParseOutput(spec)
+			if err != nil {
+				return err
+			}
+			cfg.Outputs = append(cfg.Outputs, output)
+		}
+	}
+	for _, name := range []string{"log_rotate_max_size", "log_rotate_every", "log_rotate_max_backups", "log_rotate_max_age", "log_rotate_compress", "log_reopen_on_sighup"} {
+		if c.IsSet(name) {
+			cfg.Rotation = &RotationConfig{
+				MaxSize:        int64(c.Int("log_rotate_max_size")) << 20,
+				Every:          c.Duration("log_rotate_every"),
+				MaxBackups:     c.Int("log_rotate_max_backups"),
+				MaxAge:         c.Duration("log_rotate_max_age"),
+				Compress:       c.Bool("log_rotate_compress"),
+				ReopenOnSighup: c.Bool("log_reopen_on_sighup"),
+			}
+			break
 		}
-	} else if c.String("env") == "dev" {
-		if err := NewDevelopment(c.String("log_level")); err != nil {
-			return err
+	}
+	if c.IsSet("log_sampling_initial") || c.IsSet("log_sampling_thereafter") {
+		cfg.Sampling = nil
+		initial, thereafter := c.Int("log_sampling_initial"), c.Int("log_sampling_thereafter")
+		if initial > 0 || thereafter > 0 {
+			cfg.Sampling = &SamplingConfig{Tick: time.Second, Initial: initial, Thereafter: thereafter}
+		}
+	}
+	if c.IsSet("log_rate_limit") || c.IsSet("log_rate_burst") || c.IsSet("log_rate_limit_key") {
+		rl := RateLimitConfig{Burst: c.Int("log_rate_burst")}
+		if cfg.RateLimit != nil {
+			rl = *cfg.RateLimit
+		}
+		if c.IsSet("log_rate_limit") {
+			rl.Rate = c.Float64("log_rate_limit")
+		}
+		if c.IsSet("log_rate_burst") {
+			rl.Burst = c.Int("log_rate_burst")
+		}
+		if c.IsSet("log_rate_limit_key") {
+			rl.KeyField = c.String("log_rate_limit_key")
+		}
+		cfg.RateLimit = nil
+		if rl.Rate > 0 {
+			cfg.RateLimit = &rl
+		}
+	}
+	if c.IsSet("log_recent_entries") {
+		cfg.RecentEntries = c.Int("log_recent_entries")
+	}
+	if c.Bool("log_redact") && cfg.Redact == nil {
+		cfg.Redact = NewRedactConfig()
+	}
+	if c.Bool("log_async") {
+		overflow, ok := overflowPolicies[c.String("log_async_overflow")]
+		if !ok {
+			return errors.Errorf("log: unknown async overflow policy %q, expected one of block, drop_newest, drop_oldest", c.String("log_async_overflow"))
 		}
-	} else if c.String("env") == "test" {
-		if _, err := NewTest(); err != nil {
-			return err
+		cfg.Async = &AsyncConfig{
+			QueueSize: c.Int("log_async_queue_size"),
+			Overflow:  overflow,
 		}
-	} else {
-		return cli.Exit("failed to create logger", 1)
 	}
 	return nil
 }
@@ -0,0 +1,215 @@
+package log
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// RequestIDHeader is the default header carrying the request ID.
+	RequestIDHeader = "X-Request-Id"
+	// MaxRequestIDLength is the length above which an incoming request ID is
+	// replaced by a generated one.
+	MaxRequestIDLength = 128
+)
+
+// MiddlewareOption configures Middleware.
+type MiddlewareOption func(*middleware)
+
+// WithRequestIDHeader sets the header the request ID is read from and
+// written to, RequestIDHeader by default.
+func WithRequestIDHeader(name string) MiddlewareOption {
+	return func(m *middleware) {
+		m.header = name
+	}
+}
+
+// WithRequestIDGenerator sets the function generating request IDs for
+// requests that do not carry a valid one. By default they are 16 random
+// bytes in hex.
+func WithRequestIDGenerator(fn func() string) MiddlewareOption {
+	return func(m *middleware) {
+		m.newID = fn
+	}
+}
+
+// WithSkipPaths disables the access log of requests to the given paths,
+// e.g. the health and version handlers.
+func WithSkipPaths(paths ...string) MiddlewareOption {
+	return func(m *middleware) {
+		for _, path := range paths {
+			m.skip[path] = struct{}{}
+		}
+	}
+}
+
+type middleware struct {
+	logger func() *Logger
+	header string
+	newID  func() string
+	skip   map[string]struct{}
+}
+
+// Middleware returns HTTP middleware using the global logger, see
+// Logger.Middleware.
+func Middleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return newMiddleware(L, opts)
+}
+
+// Middleware returns HTTP middleware that propagates the request ID header,
+// generating it if missing, stores a logger with the method, path, remote
+// address and request ID in the request context, see NewContext, and writes
+// an access log line with the status, bytes written and latency of each
+// request. Incoming request IDs longer than MaxRequestIDLength or with other
+// characters than letters, digits and "-_.:+/=" are replaced. A panicking
+// handler is logged at error level with the panic value, and the panic goes
+// on.
+func (l *Logger) Middleware(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return newMiddleware(func() *Logger { return l }, opts)
+}
+
+func newMiddleware(logger func() *Logger, opts []MiddlewareOption) func(http.Handler) http.Handler {
+	m := &middleware{
+		logger: logger,
+		header: RequestIDHeader,
+		newID:  newRequestID,
+		skip:   make(map[string]struct{}),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m.wrap
+}
+
+func (m *middleware) wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get(m.header)
+		if !validRequestID(id) {
+			id = m.newID()
+		}
+		w.Header().Set(m.header, id)
+
+		l := m.logger()
+		ctx := context.WithValue(r.Context(), requestIDKey, id)
+		ctx = l.NewContext(ctx,
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.String("remote_addr", r.RemoteAddr),
+			zap.String("request_id", id),
+		)
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			if p := recover(); p != nil {
+				if !rw.wroteHeader {
+					rw.status = http.StatusInternalServerError
+				}
+				logAccess(l.FromContext(ctx), rw, start, zap.Any("panic", p))
+				panic(p)
+			}
+			if _, ok := m.skip[r.URL.Path]; !ok {
+				logAccess(l.FromContext(ctx), rw, start)
+			}
+		}()
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+// logAccess writes the access log line of a request.
+func logAccess(logger *zap.Logger, rw *responseWriter, start time.Time, extra ...zap.Field) {
+	fields := append([]zap.Field{
+		zap.Int("status", rw.status),
+		zap.Int64("bytes", rw.bytes),
+		zap.Duration("latency", time.Since(start)),
+	}, extra...)
+	if rw.status >= http.StatusInternalServerError {
+		logger.Error("request", fields...)
+	} else {
+		logger.Info("request", fields...)
+	}
+}
+
+// validRequestID reports whether an incoming request ID is safe to log.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > MaxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("-_.:+/=", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDFromContext returns the request ID stored by Middleware.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// responseWriter records the status and size of a response.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if the underlying writer does.
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker if the underlying writer does.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("log: response writer does not support hijacking")
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
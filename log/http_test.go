@@ -0,0 +1,92 @@
+package log
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMiddleware(t *testing.T) {
+	logger, logs := NewTestLogger()
+	var requestID string
+	handler := logger.Middleware(WithSkipPaths("/health"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID = RequestIDFromContext(r.Context())
+		logger.FromContext(r.Context()).Info("handling")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set(RequestIDHeader, "abc")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, "abc", requestID)
+	assert.Equal(t, "abc", rec.Header().Get(RequestIDHeader))
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "handling", entries[0].Message)
+	assert.Equal(t, "request", entries[1].Message)
+	fields := entries[1].ContextMap()
+	assert.Equal(t, "GET", fields["method"])
+	assert.Equal(t, "/hello", fields["path"])
+	assert.Equal(t, "abc", fields["request_id"])
+	assert.Equal(t, int64(200), fields["status"])
+	assert.Equal(t, int64(5), fields["bytes"])
+	assert.Contains(t, fields, "latency")
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	assert.Len(t, requestID, 32)
+	assert.Equal(t, requestID, rec.Header().Get(RequestIDHeader))
+	entries = logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, zap.ErrorLevel, entries[1].Level)
+	assert.Equal(t, int64(http.StatusBadGateway), entries[1].ContextMap()["status"])
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+	entries = logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "handling", entries[0].Message)
+}
+
+func TestMiddleware_InvalidRequestID(t *testing.T) {
+	logger, logs := NewTestLogger()
+	handler := logger.Middleware()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	for _, id := range []string{"a\nfake log line", strings.Repeat("a", MaxRequestIDLength+1), `"quoted"`} {
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		assert.Len(t, rec.Header().Get(RequestIDHeader), 32, id)
+
+		entries := logs.TakeAll()
+		require.Len(t, entries, 1)
+		assert.Equal(t, rec.Header().Get(RequestIDHeader), entries[0].ContextMap()["request_id"])
+	}
+}
+
+func TestMiddleware_Panic(t *testing.T) {
+	logger, logs := NewTestLogger()
+	handler := logger.Middleware()(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		panic("boom")
+	}))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello", nil))
+	})
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zap.ErrorLevel, entries[0].Level)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, int64(http.StatusInternalServerError), fields["status"])
+	assert.Equal(t, "boom", fields["panic"])
+}
@@ -0,0 +1,335 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelController changes the levels of a logger and the loggers derived from
+// it at runtime. Levels can be set for the whole logger or per named logger,
+// where a level set for "http" also applies to "http.client" unless that has
+// its own. Levels set with Elevate and ElevateNamed revert after a duration.
+type LevelController struct {
+	// level is the effective level of the logger.
+	level zap.AtomicLevel
+	// namedMin is the lowest effective level of the named loggers.
+	namedMin zap.AtomicLevel
+	// named holds the effective map[string]zapcore.Level of named loggers.
+	named atomic.Value
+
+	mu        sync.Mutex
+	base      map[string]zapcore.Level
+	overrides map[string]*override
+	stop      chan struct{}
+}
+
+// noLevel is above all levels, enabling none of them.
+const noLevel = zapcore.FatalLevel + 1
+
+// override is a level set for a limited time.
+type override struct {
+	level zapcore.Level
+	until time.Time
+	timer *time.Timer
+}
+
+// NewLevelController creates a controller whose global level is level.
+func NewLevelController(level zap.AtomicLevel) *LevelController {
+	c := &LevelController{
+		level:     level,
+		namedMin:  zap.NewAtomicLevelAt(noLevel),
+		base:      map[string]zapcore.Level{"": level.Level()},
+		overrides: map[string]*override{},
+	}
+	c.named.Store(map[string]zapcore.Level{})
+	return c
+}
+
+// AtomicLevel returns the zap level backing the global level.
+func (c *LevelController) AtomicLevel() zap.AtomicLevel {
+	return c.level
+}
+
+// Level returns the current global level.
+func (c *LevelController) Level() zapcore.Level {
+	return c.level.Level()
+}
+
+// SetLevel sets the global level, cancelling any pending elevation.
+func (c *LevelController) SetLevel(level zapcore.Level) {
+	c.set("", level)
+}
+
+// Elevate sets the global level for d, after which the level set with
+// SetLevel is restored. A non-positive d elevates until SetLevel is called.
+func (c *LevelController) Elevate(level zapcore.Level, d time.Duration) {
+	c.elevate("", level, d)
+}
+
+// NamedLevel returns the level set for the named logger, if any.
+func (c *LevelController) NamedLevel(name string) (zapcore.Level, bool) {
+	level, ok := c.namedLevels()[name]
+	return level, ok
+}
+
+// SetNamedLevel sets the level of the named logger and its descendants,
+// cancelling any pending elevation of it.
+func (c *LevelController) SetNamedLevel(name string, level zapcore.Level) {
+	c.set(name, level)
+}
+
+// ElevateNamed sets the level of the named logger for d, after which its
+// previous level is restored. A non-positive d elevates until SetNamedLevel
+// or UnsetNamedLevel is called.
+func (c *LevelController) ElevateNamed(name string, level zapcore.Level, d time.Duration) {
+	c.elevate(name, level, d)
+}
+
+// UnsetNamedLevel makes the named logger follow the global level again.
+func (c *LevelController) UnsetNamedLevel(name string) {
+	if name == "" {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.base, name)
+	c.cancelLocked(name)
+	c.updateLocked()
+}
+
+// LevelStatus describes a level set on a LevelController.
+type LevelStatus struct {
+	Level zapcore.Level
+	// Until is when an elevated level reverts, zero if it does not.
+	Until time.Time
+}
+
+// Status returns the global level and the levels of named loggers.
+func (c *LevelController) Status() (LevelStatus, map[string]LevelStatus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	named := make(map[string]LevelStatus, len(c.base))
+	for name, level := range c.base {
+		named[name] = LevelStatus{Level: level}
+	}
+	for name, o := range c.overrides {
+		named[name] = LevelStatus{Level: o.level, Until: o.until}
+	}
+	global := named[""]
+	delete(named, "")
+	if _, ok := c.overrides[""]; !ok {
+		// The AtomicLevel may have been changed directly.
+		global.Level = c.level.Level()
+	}
+	return global, named
+}
+
+// Enabled implements zapcore.LevelEnabler. It reports whether the level is
+// enabled for the logger or any of its named loggers.
+func (c *LevelController) Enabled(level zapcore.Level) bool {
+	return c.level.Enabled(level) || c.namedMin.Enabled(level)
+}
+
+// EnabledFor reports whether the level is enabled for the named logger.
+func (c *LevelController) EnabledFor(name string, level zapcore.Level) bool {
+	if !c.Enabled(level) {
+		return false
+	}
+	named := c.namedLevels()
+	for len(named) > 0 && name != "" {
+		if l, ok := named[name]; ok {
+			return l.Enabled(level)
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return c.level.Enabled(level)
+}
+
+// WatchSignals toggles the global level on the given signals: the first
+// signal elevates to the mapped level, the next one restores the level set
+// with SetLevel. Changes are announced through logger. Calling it again
+// replaces the previous signal mapping.
+func (c *LevelController) WatchSignals(logger *zap.Logger, signals map[os.Signal]zapcore.Level) {
+	c.stopSignals()
+	if len(signals) == 0 {
+		return
+	}
+
+	ch := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	sigs := make([]os.Signal, 0, len(signals))
+	for sig := range signals {
+		sigs = append(sigs, sig)
+	}
+	signal.Notify(ch, sigs...)
+
+	c.mu.Lock()
+	c.stop = stop
+	c.mu.Unlock()
+
+	go func() {
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-stop:
+				return
+			case sig := <-ch:
+				if c.toggle(signals[sig]) {
+					logger.Info("Log level elevated", zap.Stringer("level", signals[sig]))
+				} else {
+					logger.Info("Log level restored to original configuration", zap.Stringer("level", c.Level()))
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops watching signals and cancels pending elevations, leaving the
+// levels as they currently are.
+func (c *LevelController) Stop() {
+	c.stopSignals()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, o := range c.overrides {
+		if o.timer != nil {
+			o.timer.Stop()
+			o.timer = nil
+		}
+	}
+}
+
+// stopSignals stops the goroutine started by WatchSignals.
+func (c *LevelController) stopSignals() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// toggle elevates the global level to level, or restores it if it is
+// already elevated to level. It reports whether the level was elevated.
+func (c *LevelController) toggle(level zapcore.Level) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if o, ok := c.overrides[""]; ok && o.level == level {
+		c.cancelLocked("")
+		c.updateLocked()
+		return false
+	}
+	c.elevateLocked("", level, 0)
+	return true
+}
+
+func (c *LevelController) set(name string, level zapcore.Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.base[name] = level
+	c.cancelLocked(name)
+	c.updateLocked()
+}
+
+func (c *LevelController) elevate(name string, level zapcore.Level, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.elevateLocked(name, level, d)
+}
+
+func (c *LevelController) elevateLocked(name string, level zapcore.Level, d time.Duration) {
+	if _, ok := c.overrides[""]; name == "" && !ok {
+		// Pick up changes made directly through the AtomicLevel.
+		c.base[""] = c.level.Level()
+	}
+	c.cancelLocked(name)
+	o := &override{level: level}
+	if d > 0 {
+		o.until = time.Now().Add(d)
+		o.timer = time.AfterFunc(d, func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.overrides[name] == o {
+				delete(c.overrides, name)
+				c.updateLocked()
+			}
+		})
+	}
+	c.overrides[name] = o
+	c.updateLocked()
+}
+
+// cancelLocked drops the elevation of the named logger, if any.
+func (c *LevelController) cancelLocked(name string) {
+	if o, ok := c.overrides[name]; ok {
+		if o.timer != nil {
+			o.timer.Stop()
+		}
+		delete(c.overrides, name)
+	}
+}
+
+// updateLocked recomputes the effective levels from the base levels and
+// the elevations.
+func (c *LevelController) updateLocked() {
+	named := make(map[string]zapcore.Level, len(c.base)+len(c.overrides))
+	for name, level := range c.base {
+		named[name] = level
+	}
+	for name, o := range c.overrides {
+		named[name] = o.level
+	}
+
+	global := named[""]
+	delete(named, "")
+	min := noLevel
+	for _, level := range named {
+		if level < min {
+			min = level
+		}
+	}
+
+	c.level.SetLevel(global)
+	c.namedMin.SetLevel(min)
+	c.named.Store(named)
+}
+
+func (c *LevelController) namedLevels() map[string]zapcore.Level {
+	return c.named.Load().(map[string]zapcore.Level)
+}
+
+// levelCore filters entries by the level of the logger that wrote them.
+type levelCore struct {
+	zapcore.Core
+	levels *LevelController
+}
+
+// wrap returns core filtering entries by the levels of the controller.
+func (c *LevelController) wrap(core zapcore.Core) zapcore.Core {
+	return &levelCore{Core: core, levels: c}
+}
+
+func (c *levelCore) Enabled(level zapcore.Level) bool {
+	return c.levels.Enabled(level)
+}
+
+func (c *levelCore) With(fields []zapcore.Field) zapcore.Core {
+	return &levelCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *levelCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.EnabledFor(ent.LoggerName, ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
@@ -0,0 +1,59 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestLevelController_Elevate(t *testing.T) {
+	levels := NewLevelController(zap.NewAtomicLevelAt(zap.InfoLevel))
+
+	levels.Elevate(zap.DebugLevel, 10*time.Millisecond)
+	assert.Equal(t, zap.DebugLevel, levels.Level())
+	assert.Eventually(t, func() bool {
+		return levels.Level() == zap.InfoLevel
+	}, time.Second, time.Millisecond)
+
+	levels.Elevate(zap.DebugLevel, time.Hour)
+	levels.SetLevel(zap.WarnLevel)
+	assert.Equal(t, zap.WarnLevel, levels.Level())
+	levels.Stop()
+}
+
+func TestLevelController_Toggle(t *testing.T) {
+	levels := NewLevelController(zap.NewAtomicLevelAt(zap.InfoLevel))
+
+	assert.True(t, levels.toggle(zap.DebugLevel))
+	assert.Equal(t, zap.DebugLevel, levels.Level())
+	assert.False(t, levels.toggle(zap.DebugLevel))
+	assert.Equal(t, zap.InfoLevel, levels.Level())
+}
+
+func TestLevelController_NamedLevels(t *testing.T) {
+	logger, logs := NewTestLogger()
+	levels := logger.Levels()
+	levels.SetLevel(zap.InfoLevel)
+	levels.SetNamedLevel("http", zap.DebugLevel)
+	levels.SetNamedLevel("db", zap.ErrorLevel)
+
+	logger.Zap().Debug("root")
+	logger.Named("http").Named("client").Zap().Debug("http client")
+	logger.Named("db").Zap().Warn("db")
+	logger.Named("cache").Zap().Info("cache")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "http.client", entries[0].LoggerName)
+	assert.Equal(t, "cache", entries[1].LoggerName)
+
+	levels.UnsetNamedLevel("db")
+	logger.Named("db").Zap().Warn("db")
+	assert.Equal(t, 1, logs.Len())
+
+	_, ok := levels.NamedLevel("db")
+	assert.False(t, ok)
+}
@@ -1,192 +1,132 @@
 package log
 
 import (
+	"sync"
+
 	"github.com/uber-go/tally"
 	"go.uber.org/zap"
-	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
-	"os"
-	"os/signal"
-	"syscall"
-	"time"
 )
 
-type logger struct {
-	zap *zap.Logger
-}
-
 var (
-	wrappedLogger = &logger{}
+	mu            sync.RWMutex
+	wrappedLogger = &Logger{zap: zap.NewNop(), levels: NewLevelController(zap.NewAtomicLevel()), drops: newDropCounter()}
+	// ownedLogger is the global Logger if it was built and installed by this
+	// package, which then closes it when installing the next one.
+	ownedLogger *Logger
 )
 
+// L returns the global Logger.
+func L() *Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return wrappedLogger
+}
+
+// ReplaceGlobal replaces the global Logger, which is also the fallback of
+// FromContext, and returns a function restoring the previous one. Neither
+// logger is closed, the caller keeps the responsibility for them.
+func ReplaceGlobal(l *Logger) func() {
+	mu.Lock()
+	prev := wrappedLogger
+	wrappedLogger = l
+	ownedLogger = nil
+	mu.Unlock()
+	return func() {
+		ReplaceGlobal(prev)
+	}
+}
+
+// installGlobal replaces the global Logger with one newly built by this
+// package and closes the previous one if this package built and installed
+// it too, see Logger.Close.
+func installGlobal(l *Logger) {
+	mu.Lock()
+	prev, owned := wrappedLogger, ownedLogger
+	wrappedLogger, ownedLogger = l, l
+	mu.Unlock()
+	if prev == owned && prev != nil && prev.levels != l.levels {
+		prev.Close()
+	}
+}
+
+// updateGlobal atomically replaces the global Logger with the result of fn,
+// which derives it from the current one and keeps its outputs.
+func updateGlobal(fn func(*Logger) *Logger) {
+	mu.Lock()
+	defer mu.Unlock()
+	owned := wrappedLogger == ownedLogger
+	wrappedLogger = fn(wrappedLogger)
+	if owned {
+		ownedLogger = wrappedLogger
+	}
+}
+
 // Get gets global logger
 func Get() *zap.Logger {
-	return wrappedLogger.zap
+	return L().zap
 }
 
 // New setups Zap to the correct log level and correct output format.
 func New(logFormat, logLevel string) error {
-	var zapConfig zap.Config
-	switch logFormat {
-	case "json":
-		zapConfig = zap.NewProductionConfig()
-		zapConfig.DisableStacktrace = true
-	default:
-		zapConfig = zap.NewDevelopmentConfig()
-		zapConfig.DisableStacktrace = true
-		zapConfig.DisableCaller = true
-		zapConfig.EncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {}
-		zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	}
-
-	zapConfig.EncoderConfig.TimeKey = "timestamp"
-	zapConfig.EncoderConfig.LevelKey = "log_level"
-	zapConfig.EncoderConfig.MessageKey = "message"
-	zapConfig.EncoderConfig.EncodeTime = func(t time.Time, encoder zapcore.PrimitiveArrayEncoder) {
-		encoder.AppendString(t.Format("2006-01-02T15:04:05.000Z07:00"))
+	cfg := NewProductionConfig()
+	cfg.Format = logFormat
+	cfg.Level = logLevel
+	if cfg.Format == FormatConsole {
+		cfg.DisableCaller = true
 	}
+	return NewWithConfig(cfg)
+}
 
-	// Set the logger
-	switch logLevel {
-	case "debug":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
-	case "info":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	case "warn":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
-	case "error":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
-	case "fatal":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.FatalLevel)
-	default:
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	}
-	logger, err := zapConfig.Build()
+// NewWithConfig builds the global logger from cfg. The level signals of cfg
+// are watched until the global logger is replaced by the next call.
+func NewWithConfig(cfg Config) error {
+	l, err := NewLogger(cfg)
 	if err != nil {
 		return err
 	}
-
-	go func(config zap.Config) {
-
-		defaultLevel := config.Level
-		var elevated bool
-
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt, syscall.SIGUSR1)
-		for s := range c {
-			if s == syscall.SIGINT {
-				return
-			}
-			elevated = !elevated
-
-			if elevated {
-				config.Level.SetLevel(zap.DebugLevel)
-				logger.Info("Log level elevated to debug")
-			} else {
-				logger.Info("Log level restored to original configuration", zap.String("level", logLevel))
-				config.Level.SetLevel(defaultLevel.Level())
-			}
-		}
-	}(zapConfig)
-	wrappedLogger.zap = logger
+	l.levels.WatchSignals(l.zap, cfg.levelSignals())
+	installGlobal(l)
 	return nil
 }
 
+// Close flushes the global logger before shutdown, see Logger.Close.
+func Close() error {
+	return L().Close()
+}
+
 // NameLogger named core logger
 func NameLogger(name string) {
-	wrappedLogger.zap = wrappedLogger.zap.Named(name)
+	updateGlobal(func(l *Logger) *Logger {
+		return l.Named(name)
+	})
 }
 
 // LoggerWithMetrics add hook to zap.Logger which count log levels
-func LoggerWithMetrics(statsReporter tally.Scope) {
-	if statsReporter != nil {
-		wrappedLogger.zap = wrappedLogger.zap.WithOptions(zap.Hooks(func(entry zapcore.Entry) error {
-			if entry.Level == zap.ErrorLevel {
-				statsReporter.Counter("error_count").Inc(1)
-			}
-			return nil
-		}))
-	}
+func LoggerWithMetrics(statsReporter tally.Scope, opts ...MetricsOption) {
+	updateGlobal(func(l *Logger) *Logger {
+		return l.WithMetrics(statsReporter, opts...)
+	})
 }
 
-// LoggerWithMetrics add hook to zap.Logger which count log levels
-func LoggerWithErrorMetrics(scope tally.Scope, extractor TagsExtractor, metricName string) {
-	if scope != nil {
-		wrappedLogger.zap = wrappedLogger.zap.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
-			return NewErrorMetricsCore(core, extractor, metricName, scope)
-		}))
-	}
+// LoggerWithErrorMetrics add core to zap.Logger which count errors tagged by extractor
+func LoggerWithErrorMetrics(scope tally.Scope, extractor TagsExtractor, metricName string, opts ...ErrorMetricsOption) {
+	updateGlobal(func(l *Logger) *Logger {
+		return l.WithErrorMetrics(scope, extractor, metricName, opts...)
+	})
 }
 
 // NewDevelopment setups Zap to the correct log level and correct output format for development.
 func NewDevelopment(logLevel string) error {
-	var zapConfig zap.Config
-
-	zapConfig = zap.NewDevelopmentConfig()
-	zapConfig.DisableStacktrace = true
-	zapConfig.DisableCaller = false
-	zapConfig.EncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {}
-	zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-
-	// Set the logger
-	switch logLevel {
-	case "debug":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
-	case "info":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	case "warn":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.WarnLevel)
-	case "error":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.ErrorLevel)
-	case "fatal":
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.FatalLevel)
-	default:
-		zapConfig.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	}
-
-	zapConfig.EncoderConfig.TimeKey = "timestamp"
-	zapConfig.EncoderConfig.LevelKey = "log_level"
-	zapConfig.EncoderConfig.MessageKey = "message"
-	zapConfig.EncoderConfig.EncodeTime = func(t time.Time, encoder zapcore.PrimitiveArrayEncoder) {
-		encoder.AppendString(t.Format("2006-01-02T15:04:05.000Z07:00"))
-	}
-
-	logger, err := zapConfig.Build()
-	if err != nil {
-		return err
-	}
-	wrappedLogger.zap = logger
-	return nil
+	cfg := NewDevelopmentConfig()
+	cfg.Level = logLevel
+	return NewWithConfig(cfg)
 }
 
 // NewTest setups Zap for tests.
 func NewTest() (*observer.ObservedLogs, error) {
-	var zapConfig zap.Config
-
-	zapConfig = zap.NewDevelopmentConfig()
-	zapConfig.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
-	zapConfig.DisableStacktrace = true
-	zapConfig.DisableCaller = true
-	zapConfig.EncoderConfig.EncodeTime = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {}
-	zapConfig.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-
-	zapConfig.EncoderConfig.TimeKey = "timestamp"
-	zapConfig.EncoderConfig.LevelKey = "log_level"
-	zapConfig.EncoderConfig.MessageKey = "message"
-	zapConfig.EncoderConfig.EncodeTime = func(t time.Time, encoder zapcore.PrimitiveArrayEncoder) {
-		encoder.AppendString(t.Format("2006-01-02T15:04:05.000Z07:00"))
-	}
-
-	logger, err := zapConfig.Build()
-	if err != nil {
-		return nil, err
-	}
-
-	testCore, logs := observer.New(zap.DebugLevel)
-	logger = logger.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core {
-		return testCore
-	}))
-	wrappedLogger.zap = logger
+	l, logs := NewTestLogger()
+	installGlobal(l)
 
 	return logs, nil
 }
@@ -0,0 +1,218 @@
+package log
+
+import (
+	"context"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// Logger is a logger handle independent of the global one. The package level
+// functions are thin wrappers around a global Logger; build your own with
+// NewLogger when the shared state gets in the way, e.g. in parallel tests.
+type Logger struct {
+	zap    *zap.Logger
+	levels *LevelController
+	drops  *dropCounter
+	async  *AsyncWriter
+	recent *RecentEntries
+	// closeOutputs closes the files and sinks the outputs write to.
+	closeOutputs func()
+	// outputs is the core writing to the outputs regardless of the level,
+	// wrapped like the logger by wrapOutputs, see ContextWithFingersCrossed.
+	outputs zapcore.Core
+}
+
+// Option configures a Logger built by NewLogger.
+type Option func(*Logger)
+
+// WithName names the logger.
+func WithName(name string) Option {
+	return func(l *Logger) {
+		*l = *l.Named(name)
+	}
+}
+
+// WithMetrics counts logged entries into scope.
+func WithMetrics(scope tally.Scope, opts ...MetricsOption) Option {
+	return func(l *Logger) {
+		*l = *l.WithMetrics(scope, opts...)
+	}
+}
+
+// WithErrorMetrics counts error entries into scope, tagged by extractor.
+func WithErrorMetrics(scope tally.Scope, extractor TagsExtractor, metricName string, opts ...ErrorMetricsOption) Option {
+	return func(l *Logger) {
+		*l = *l.WithErrorMetrics(scope, extractor, metricName, opts...)
+	}
+}
+
+// WithZapOptions applies zap options to the logger.
+func WithZapOptions(opts ...zap.Option) Option {
+	return func(l *Logger) {
+		*l = *l.clone(l.zap.WithOptions(opts...))
+	}
+}
+
+// NewLogger builds a Logger from cfg without touching the global logger.
+func NewLogger(cfg Config, opts ...Option) (*Logger, error) {
+	l, err := cfg.build()
+	if err != nil {
+		return nil, err
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l, nil
+}
+
+// NewTestLogger builds a Logger that records entries at all levels in memory.
+func NewTestLogger(opts ...Option) (*Logger, *observer.ObservedLogs) {
+	var logs *observer.ObservedLogs
+	opts = append([]Option{func(l *Logger) {
+		l.zap = l.zap.WithOptions(zap.WrapCore(func(zapcore.Core) zapcore.Core {
+			var testCore zapcore.Core
+			testCore, logs = observer.New(l.levels)
+			l.outputs = &unleveledCore{Core: testCore}
+			return l.levels.wrap(testCore)
+		}))
+	}}, opts...)
+	l, err := NewLogger(NewTestConfig(), opts...)
+	if err != nil {
+		// NewTestConfig is always valid and has no outputs to open.
+		panic(err)
+	}
+	return l, logs
+}
+
+// Zap returns the underlying zap logger.
+func (l *Logger) Zap() *zap.Logger {
+	return l.zap
+}
+
+// Levels returns the level controller shared by the logger and all loggers
+// derived from it.
+func (l *Logger) Levels() *LevelController {
+	return l.levels
+}
+
+// Close stops the level controller, flushes and stops the asynchronous
+// output, if any, and closes the outputs. It affects all loggers derived
+// from this one.
+func (l *Logger) Close() error {
+	l.levels.Stop()
+	var err error
+	if l.async != nil {
+		err = l.async.Close()
+	} else {
+		err = l.zap.Sync()
+	}
+	if l.closeOutputs != nil {
+		l.closeOutputs()
+	}
+	return err
+}
+
+// Named returns a copy of the logger with name appended to its name.
+func (l *Logger) Named(name string) *Logger {
+	return l.clone(l.zap.Named(name))
+}
+
+// WithMetrics returns a copy of the logger which counts entries into
+// <level>_count counters of scope, tagged by logger name. It also reports
+// the queue of the asynchronous output, see AsyncWriter.ReportMetrics.
+func (l *Logger) WithMetrics(scope tally.Scope, opts ...MetricsOption) *Logger {
+	if scope == nil {
+		return l
+	}
+	var cfg metricsConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.droppedCounter != "" {
+		l.drops.report(scope, cfg.droppedCounter)
+	}
+	if l.async != nil {
+		l.async.ReportMetrics(scope)
+	}
+	hook := newLevelCounters(scope).hook
+	return l.wrapOutputs(func(core zapcore.Core) zapcore.Core {
+		return zapcore.RegisterHooks(core, hook)
+	})
+}
+
+// WithErrorMetrics returns a copy of the logger which counts error entries
+// into metricName of scope, see NewErrorMetricsCore.
+func (l *Logger) WithErrorMetrics(scope tally.Scope, extractor TagsExtractor, metricName string, opts ...ErrorMetricsOption) *Logger {
+	if scope == nil {
+		return l
+	}
+	return l.wrapOutputs(func(core zapcore.Core) zapcore.Core {
+		return NewErrorMetricsCore(core, extractor, metricName, scope, opts...)
+	})
+}
+
+// wrapOutputs returns a copy of the logger with fn applied to its core,
+// beneath the capture of recent entries, so that fn only sees the entries
+// written to the outputs. fn is applied to the outputs core too, which
+// writes the entries kept by ContextWithFingersCrossed.
+func (l *Logger) wrapOutputs(fn func(zapcore.Core) zapcore.Core) *Logger {
+	c := l.clone(l.zap.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if rc, ok := core.(*recentCore); ok {
+			return rc.wrap(fn)
+		}
+		return fn(core)
+	})))
+	if l.outputs != nil {
+		c.outputs = fn(l.outputs)
+	}
+	return c
+}
+
+// Recent returns the recent entries kept by the logger, or nil if
+// Config.RecentEntries is 0.
+func (l *Logger) Recent() *RecentEntries {
+	return l.recent
+}
+
+// NewContext creates a new context with this logger, replacing the one of
+// ctx if any, and the given contextual fields. The fields override fields
+// of ctx with the same keys and are kept by ContextWithFields.
+func (l *Logger) NewContext(ctx context.Context, fields ...zapcore.Field) context.Context {
+	return contextWithBoundFields(context.WithValue(ctx, loggerKey, l.zap), fields)
+}
+
+// FromContext returns the logger stored in ctx, falling back to this one,
+// with the fields of ctx and the IDs of its OpenTelemetry span, if any. In
+// a context from ContextWithFingersCrossed it keeps disabled entries until
+// an error is logged.
+func (l *Logger) FromContext(ctx context.Context) *zap.Logger {
+	if ctx == nil {
+		return l.zap
+	}
+	logger := l.contextLogger(ctx)
+	if buf := crossedFromContext(ctx); buf != nil && l.outputs != nil {
+		logger = buf.logger(logger, l.outputs)
+	}
+	if node := traceNode(ctx, fieldsFromContext(ctx)); node != nil {
+		logger = node.logger(logger)
+	}
+	return logger
+}
+
+// contextLogger returns the logger stored in ctx, falling back to this one.
+func (l *Logger) contextLogger(ctx context.Context) *zap.Logger {
+	if ctxLogger, ok := ctx.Value(loggerKey).(*zap.Logger); ok {
+		return ctxLogger
+	}
+	return l.zap
+}
+
+// clone returns a copy of the logger wrapping z.
+func (l *Logger) clone(z *zap.Logger) *Logger {
+	c := *l
+	c.zap = z
+	return &c
+}
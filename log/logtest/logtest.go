@@ -0,0 +1,251 @@
+// Package logtest provides a test logger with assertions on the logged
+// entries.
+package logtest
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/MrEhbr/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+var update = flag.Bool("logtest.update", false, "update the golden files of logtest")
+
+// Logs records the entries of a test logger.
+type Logs struct {
+	t      testing.TB
+	logger *log.Logger
+	logs   *observer.ObservedLogs
+	json   *syncBuffer
+}
+
+// New returns a logger recording entries at all levels, installed as the
+// global logger until the end of the test.
+func New(t testing.TB, opts ...log.Option) *Logs {
+	t.Helper()
+	l := &Logs{t: t, json: &syncBuffer{}}
+	opts = append(opts, log.WithZapOptions(zap.WrapCore(func(c zapcore.Core) zapcore.Core {
+		return zapcore.NewTee(c, zapcore.NewCore(jsonEncoder(), zapcore.AddSync(l.json), c))
+	})))
+	l.logger, l.logs = log.NewTestLogger(opts...)
+	t.Cleanup(log.ReplaceGlobal(l.logger))
+	return l
+}
+
+// jsonEncoder encodes entries like the production JSON format, without the
+// time and caller, so that the output is stable.
+func jsonEncoder() zapcore.Encoder {
+	keys := log.NewProductionConfig().Keys
+	return zapcore.NewJSONEncoder(zapcore.EncoderConfig{
+		LevelKey:       keys.Level,
+		NameKey:        keys.Name,
+		MessageKey:     keys.Message,
+		StacktraceKey:  keys.Stacktrace,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeDuration: zapcore.SecondsDurationEncoder,
+	})
+}
+
+// Logger returns the test logger.
+func (l *Logs) Logger() *log.Logger {
+	return l.logger
+}
+
+// Entries returns a query over all entries logged so far.
+func (l *Logs) Entries() *Query {
+	return &Query{t: l.t, entries: l.logs.All()}
+}
+
+// AssertLogged asserts that an entry with the message was logged at level
+// with the fields.
+func (l *Logs) AssertLogged(level zapcore.Level, msg string, fields ...zap.Field) {
+	l.t.Helper()
+	l.Entries().AtLevel(level).WithMessage(msg).WithFields(fields...).AssertLogged()
+}
+
+// AssertCount asserts the number of entries logged so far.
+func (l *Logs) AssertCount(n int) {
+	l.t.Helper()
+	l.Entries().AssertCount(n)
+}
+
+// AssertNoErrors asserts that nothing was logged at error level or above.
+func (l *Logs) AssertNoErrors() {
+	l.t.Helper()
+	l.Entries().AtLeast(zap.ErrorLevel).AssertNotLogged()
+}
+
+// JSON returns the entries logged so far as JSON lines, without time and
+// caller.
+func (l *Logs) JSON() string {
+	return l.json.String()
+}
+
+// AssertGolden compares the JSON output, see JSON, with the golden file at
+// path. Running the tests with -logtest.update writes the file instead.
+func (l *Logs) AssertGolden(path string) {
+	l.t.Helper()
+	got := l.JSON()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			l.t.Fatalf("logtest: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			l.t.Fatalf("logtest: %v", err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		l.t.Fatalf("logtest: reading golden file, run with -logtest.update to create it: %v", err)
+	}
+	assert.Equal(l.t, string(want), got, "log output differs from %s", path)
+}
+
+// Query filters logged entries and asserts on the result. Filters return a
+// new query.
+type Query struct {
+	t       testing.TB
+	entries []observer.LoggedEntry
+	filters []string
+}
+
+func (q *Query) filter(desc string, match func(observer.LoggedEntry) bool) *Query {
+	filtered := &Query{t: q.t, filters: append(q.filters[:len(q.filters):len(q.filters)], desc)}
+	for _, e := range q.entries {
+		if match(e) {
+			filtered.entries = append(filtered.entries, e)
+		}
+	}
+	return filtered
+}
+
+// AtLevel keeps the entries logged at level.
+func (q *Query) AtLevel(level zapcore.Level) *Query {
+	return q.filter("level "+level.String(), func(e observer.LoggedEntry) bool {
+		return e.Level == level
+	})
+}
+
+// AtLeast keeps the entries logged at level or above.
+func (q *Query) AtLeast(level zapcore.Level) *Query {
+	return q.filter("level >= "+level.String(), func(e observer.LoggedEntry) bool {
+		return e.Level >= level
+	})
+}
+
+// WithMessage keeps the entries with the message.
+func (q *Query) WithMessage(msg string) *Query {
+	return q.filter("message "+msg, func(e observer.LoggedEntry) bool {
+		return e.Message == msg
+	})
+}
+
+// WithMessageContaining keeps the entries whose message contains substr.
+func (q *Query) WithMessageContaining(substr string) *Query {
+	return q.filter("message containing "+substr, func(e observer.LoggedEntry) bool {
+		return strings.Contains(e.Message, substr)
+	})
+}
+
+// Named keeps the entries of the logger with the name.
+func (q *Query) Named(name string) *Query {
+	return q.filter("logger "+name, func(e observer.LoggedEntry) bool {
+		return e.LoggerName == name
+	})
+}
+
+// WithFields keeps the entries with all the fields.
+func (q *Query) WithFields(fields ...zap.Field) *Query {
+	if len(fields) == 0 {
+		return q
+	}
+	keys := make([]string, len(fields))
+	for i, f := range fields {
+		keys[i] = f.Key
+	}
+	return q.filter("fields "+strings.Join(keys, ", "), func(e observer.LoggedEntry) bool {
+		for _, f := range fields {
+			if !hasField(e.Context, f) {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+func hasField(fields []zap.Field, want zap.Field) bool {
+	for _, f := range fields {
+		if f.Equals(want) {
+			return true
+		}
+	}
+	return false
+}
+
+// All returns the entries.
+func (q *Query) All() []observer.LoggedEntry {
+	return q.entries
+}
+
+// AssertLogged asserts that at least one entry matches.
+func (q *Query) AssertLogged() *Query {
+	q.t.Helper()
+	if len(q.entries) == 0 {
+		q.t.Errorf("logtest: no entry with %s", q.describe())
+	}
+	return q
+}
+
+// AssertNotLogged asserts that no entry matches.
+func (q *Query) AssertNotLogged() *Query {
+	q.t.Helper()
+	if len(q.entries) > 0 {
+		q.t.Errorf("logtest: unexpected entry with %s: %s", q.describe(), q.entries[0].Message)
+	}
+	return q
+}
+
+// AssertCount asserts the number of matching entries.
+func (q *Query) AssertCount(n int) *Query {
+	q.t.Helper()
+	if len(q.entries) != n {
+		q.t.Errorf("logtest: %d entries with %s, expected %d", len(q.entries), q.describe(), n)
+	}
+	return q
+}
+
+func (q *Query) describe() string {
+	if len(q.filters) == 0 {
+		return "any content"
+	}
+	return strings.Join(q.filters, " and ")
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
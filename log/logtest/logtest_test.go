@@ -0,0 +1,54 @@
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/MrEhbr/pkg/log"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+// recorder records the failures of assertions expected to fail.
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func TestLogs(t *testing.T) {
+	logs := New(t)
+	log.FromContext(log.NewContext(context.Background(), zap.String("request_id", "42"))).Info("started", zap.Int("attempt", 1))
+	log.L().Named("db").Zap().Debug("query")
+
+	logs.AssertLogged(zap.InfoLevel, "started", zap.String("request_id", "42"), zap.Int("attempt", 1))
+	logs.AssertCount(2)
+	logs.AssertNoErrors()
+	logs.Entries().Named("db").WithMessageContaining("que").AssertCount(1)
+	logs.AssertGolden("testdata/logs.golden")
+
+	r := &recorder{TB: t}
+	logs.t = r
+	logs.AssertLogged(zap.InfoLevel, "started", zap.Int("attempt", 2))
+	log.L().Zap().Error("failed")
+	logs.AssertNoErrors()
+	assert.Equal(t, []string{
+		"logtest: no entry with level info and message started and fields attempt",
+		"logtest: unexpected entry with level >= error: failed",
+	}, r.failures)
+}
+
+func TestNew_Restore(t *testing.T) {
+	global := log.L()
+	t.Run("sub", func(t *testing.T) {
+		logs := New(t)
+		assert.Same(t, logs.Logger(), log.L())
+	})
+	assert.Same(t, global, log.L())
+}
@@ -0,0 +1,155 @@
+package log
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// loggerTag is the tag holding the logger name on log metrics.
+	loggerTag = "logger"
+	// rootLoggerName is the loggerTag value of entries from unnamed loggers.
+	rootLoggerName = "root"
+	// numLevels is the number of levels from debug to fatal.
+	numLevels = int(zap.FatalLevel-zap.DebugLevel) + 1
+)
+
+// dropReason is the reason an entry was dropped before the outputs.
+type dropReason int
+
+const (
+	// dropReasonSampling tags entries dropped by sampling.
+	dropReasonSampling dropReason = iota
+	// dropReasonRateLimit tags entries dropped by the rate limit.
+	dropReasonRateLimit
+	numDropReasons
+)
+
+// dropReasonTags are the reason tag values of the drop reasons.
+var dropReasonTags = [numDropReasons]string{
+	dropReasonSampling:  "sampling",
+	dropReasonRateLimit: "rate_limit",
+}
+
+// MetricsOption configures the metrics reported by WithMetrics.
+type MetricsOption func(*metricsConfig)
+
+type metricsConfig struct {
+	droppedCounter string
+}
+
+// DroppedCounter also counts entries dropped by sampling or rate limiting
+// into a counter with the given name, tagged by logger name, level and
+// reason.
+func DroppedCounter(name string) MetricsOption {
+	return func(c *metricsConfig) {
+		c.droppedCounter = name
+	}
+}
+
+// levelCounters caches the <level>_count counters per logger name, so that
+// counting an entry does not build tagged scopes.
+type levelCounters struct {
+	scope  tally.Scope
+	byName sync.Map // logger name -> *[numLevels]tally.Counter
+}
+
+func newLevelCounters(scope tally.Scope) *levelCounters {
+	return &levelCounters{scope: scope}
+}
+
+// hook counts the entry into the counter of its level and logger name.
+func (c *levelCounters) hook(entry zapcore.Entry) error {
+	if entry.Level < zap.DebugLevel || entry.Level > zap.FatalLevel {
+		return nil
+	}
+	counters, ok := c.byName.Load(entry.LoggerName)
+	if !ok {
+		counters, _ = c.byName.LoadOrStore(entry.LoggerName, c.newCounters(entry.LoggerName))
+	}
+	counters.(*[numLevels]tally.Counter)[entry.Level-zap.DebugLevel].Inc(1)
+	return nil
+}
+
+func (c *levelCounters) newCounters(name string) *[numLevels]tally.Counter {
+	scope := c.scope.Tagged(map[string]string{loggerTag: loggerTagValue(name)})
+	var counters [numLevels]tally.Counter
+	for i := range counters {
+		counters[i] = scope.Counter((zap.DebugLevel + zapcore.Level(i)).String() + "_count")
+	}
+	return &counters
+}
+
+// dropCounter counts entries dropped before reaching the outputs. It is
+// created with the logger, so that the cores dropping entries can report
+// into it, and enabled later by WithMetrics.
+type dropCounter struct {
+	counter atomic.Value // *droppedCounter
+}
+
+// droppedCounter caches the dropped counters per logger name, level and
+// reason, so that counting a drop does not build tagged scopes.
+type droppedCounter struct {
+	scope  tally.Scope
+	name   string
+	byName sync.Map // logger name -> *[numLevels][numDropReasons]tally.Counter
+}
+
+func newDropCounter() *dropCounter {
+	d := &dropCounter{}
+	d.counter.Store((*droppedCounter)(nil))
+	return d
+}
+
+// report starts counting drops into the named counter of scope.
+func (d *dropCounter) report(scope tally.Scope, name string) {
+	d.counter.Store(&droppedCounter{scope: scope, name: name})
+}
+
+// inc counts an entry dropped for reason.
+func (d *dropCounter) inc(entry zapcore.Entry, reason dropReason) {
+	if d == nil || entry.Level < zap.DebugLevel || entry.Level > zap.FatalLevel {
+		return
+	}
+	c := d.counter.Load().(*droppedCounter)
+	if c == nil {
+		return
+	}
+	counters, ok := c.byName.Load(entry.LoggerName)
+	if !ok {
+		counters, _ = c.byName.LoadOrStore(entry.LoggerName, c.newCounters(entry.LoggerName))
+	}
+	counters.(*[numLevels][numDropReasons]tally.Counter)[entry.Level-zap.DebugLevel][reason].Inc(1)
+}
+
+func (c *droppedCounter) newCounters(name string) *[numLevels][numDropReasons]tally.Counter {
+	var counters [numLevels][numDropReasons]tally.Counter
+	for i := range counters {
+		for reason := range counters[i] {
+			counters[i][reason] = c.scope.Tagged(map[string]string{
+				loggerTag: loggerTagValue(name),
+				"level":   (zap.DebugLevel + zapcore.Level(i)).String(),
+				"reason":  dropReasonTags[reason],
+			}).Counter(c.name)
+		}
+	}
+	return &counters
+}
+
+// samplerHook counts the entries dropped by the sampler.
+func (d *dropCounter) samplerHook(entry zapcore.Entry, dec zapcore.SamplingDecision) {
+	if dec&zapcore.LogDropped != 0 {
+		d.inc(entry, dropReasonSampling)
+	}
+}
+
+func loggerTagValue(name string) string {
+	if name == "" {
+		return rootLoggerName
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// counterValue sums the counters with the given name and tags in scope.
+func counterValue(scope tally.TestScope, name string, tags map[string]string) int64 {
+	var total int64
+	for _, c := range scope.Snapshot().Counters() {
+		if c.Name() != name {
+			continue
+		}
+		match := true
+		for k, v := range tags {
+			if c.Tags()[k] != v {
+				match = false
+			}
+		}
+		if match {
+			total += c.Value()
+		}
+	}
+	return total
+}
+
+func TestLogger_WithMetrics(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	logger, _ := NewTestLogger(WithMetrics(scope))
+
+	logger.Zap().Debug("debug")
+	logger.Zap().Warn("warn")
+	logger.Named("db").Zap().Error("error")
+	logger.Named("db").Zap().Error("error")
+
+	assert.EqualValues(t, 1, counterValue(scope, "debug_count", map[string]string{"logger": "root"}))
+	assert.EqualValues(t, 1, counterValue(scope, "warn_count", map[string]string{"logger": "root"}))
+	assert.EqualValues(t, 2, counterValue(scope, "error_count", map[string]string{"logger": "db"}))
+	assert.EqualValues(t, 0, counterValue(scope, "info_count", nil))
+}
+
+func TestLogger_WithMetrics_Dropped(t *testing.T) {
+	cfg := NewTestConfig()
+	cfg.Sampling = &SamplingConfig{Tick: time.Minute, Initial: 2, Thereafter: 0}
+	scope := tally.NewTestScope("", nil)
+	logger, err := NewLogger(cfg, WithMetrics(scope, DroppedCounter("dropped_count")))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		logger.Zap().Info("repeated")
+	}
+
+	assert.EqualValues(t, 2, counterValue(scope, "info_count", nil))
+	assert.EqualValues(t, 3, counterValue(scope, "dropped_count", map[string]string{"level": "info"}))
+}
+
+func TestDropCounter(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	drops := newDropCounter()
+	drops.inc(zapcore.Entry{Level: zap.InfoLevel}, dropReasonSampling)
+	drops.report(scope, "dropped_count")
+
+	ent := zapcore.Entry{LoggerName: "named", Level: zap.WarnLevel}
+	drops.inc(ent, dropReasonRateLimit)
+	allocs := testing.AllocsPerRun(100, func() {
+		drops.inc(ent, dropReasonRateLimit)
+	})
+
+	assert.Zero(t, allocs, "counting a drop must use the cached counter")
+	assert.EqualValues(t, 102, counterValue(scope, "dropped_count", map[string]string{"logger": "named", "level": "warn", "reason": "rate_limit"}))
+	assert.EqualValues(t, 102, counterValue(scope, "dropped_count", nil))
+}
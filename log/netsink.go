@@ -0,0 +1,347 @@
+package log
+
+import (
+	"bytes"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultDialTimeout  = 5 * time.Second
+	defaultWriteTimeout = 5 * time.Second
+	defaultMinBackoff   = 100 * time.Millisecond
+	defaultMaxBackoff   = 30 * time.Second
+
+	// maxSyslogAppName is the maximum length of APP-NAME in RFC 5424.
+	maxSyslogAppName = 48
+)
+
+// sinkErrors are the errors registering the network writers as zap sinks,
+// by scheme. Config.Validate reports them for the outputs using the scheme,
+// which another package registered first.
+var sinkErrors = make(map[string]error)
+
+func init() {
+	// The network writers are available as output URLs, e.g.
+	// syslog+udp://localhost:514 or ndjson+tcp://localhost:24224.
+	for _, network := range []string{"udp", "tcp", "unix", "unixgram"} {
+		network := network
+		registerSink("syslog+"+network, func(u *url.URL) (zap.Sink, error) {
+			cfg := SyslogConfig{Network: network, Address: u.Host, AppName: u.Query().Get("app")}
+			if network == "unix" || network == "unixgram" {
+				cfg.Address = u.Path
+			}
+			if facility := u.Query().Get("facility"); facility != "" {
+				f, err := strconv.Atoi(facility)
+				if err != nil {
+					return nil, errors.Wrapf(err, "log: syslog facility of %s", u)
+				}
+				cfg.Facility = f
+			}
+			return NewSyslogWriter(cfg), nil
+		})
+	}
+	registerSink("ndjson+tcp", func(u *url.URL) (zap.Sink, error) {
+		return NewTCPWriter(u.Host, NetConfig{}), nil
+	})
+}
+
+func registerSink(scheme string, factory func(*url.URL) (zap.Sink, error)) {
+	if err := zap.RegisterSink(scheme, factory); err != nil {
+		sinkErrors[scheme] = err
+	}
+}
+
+// checkSink returns the error registering the sink of output, if any.
+func checkSink(output string) error {
+	if len(sinkErrors) == 0 {
+		return nil
+	}
+	u, err := url.Parse(output)
+	if err != nil {
+		return nil
+	}
+	if err := sinkErrors[strings.ToLower(u.Scheme)]; err != nil {
+		return errors.Wrapf(err, "log: output %s", output)
+	}
+	return nil
+}
+
+// NetConfig configures the connection of network writers. A writer dials
+// on first write and after a failed write. Failed dials are retried after a
+// backoff doubling from MinBackoff up to MaxBackoff, and writes in the
+// meantime fail instead of blocking.
+type NetConfig struct {
+	// DialTimeout limits connecting, 5s if 0.
+	DialTimeout time.Duration
+	// WriteTimeout limits each write, 5s if 0.
+	WriteTimeout time.Duration
+	// MinBackoff is the first delay before redialing, 100ms if 0.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay before redialing, 30s if 0.
+	MaxBackoff time.Duration
+}
+
+// netConn is a connection that is redialed with backoff when it breaks.
+type netConn struct {
+	network string
+	address string
+	cfg     NetConfig
+
+	mu      sync.Mutex
+	conn    net.Conn
+	backoff time.Duration
+	retryAt time.Time
+	closed  bool
+}
+
+func newNetConn(network, address string, cfg NetConfig) *netConn {
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaultDialTimeout
+	}
+	if cfg.WriteTimeout <= 0 {
+		cfg.WriteTimeout = defaultWriteTimeout
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = defaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = defaultMaxBackoff
+	}
+	return &netConn{network: network, address: address, cfg: cfg}
+}
+
+// write writes p in a single write, redialing once if the connection broke.
+func (c *netConn) write(p []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return errors.Errorf("log: write to closed %s connection to %s", c.network, c.address)
+	}
+	for attempt := 0; ; attempt++ {
+		if c.conn == nil {
+			if err := c.dialLocked(); err != nil {
+				return err
+			}
+		}
+		c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+		_, err := c.conn.Write(p)
+		if err == nil {
+			return nil
+		}
+		c.conn.Close()
+		c.conn = nil
+		if attempt > 0 {
+			return errors.Wrapf(err, "log: writing to %s %s", c.network, c.address)
+		}
+	}
+}
+
+func (c *netConn) dialLocked() error {
+	if now := time.Now(); now.Before(c.retryAt) {
+		return errors.Errorf("log: %s %s unavailable, redialing in %s", c.network, c.address, c.retryAt.Sub(now).Round(time.Millisecond))
+	}
+	conn, err := net.DialTimeout(c.network, c.address, c.cfg.DialTimeout)
+	if err != nil {
+		c.backoff *= 2
+		if c.backoff < c.cfg.MinBackoff {
+			c.backoff = c.cfg.MinBackoff
+		}
+		if c.backoff > c.cfg.MaxBackoff {
+			c.backoff = c.cfg.MaxBackoff
+		}
+		c.retryAt = time.Now().Add(c.backoff)
+		return errors.Wrapf(err, "log: dialing %s %s", c.network, c.address)
+	}
+	c.conn, c.backoff, c.retryAt = conn, 0, time.Time{}
+	return nil
+}
+
+func (c *netConn) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// TCPWriter is a zapcore.WriteSyncer sending entries as newline-delimited
+// JSON over TCP, e.g. to a fluent-bit tcp input.
+type TCPWriter struct {
+	conn *netConn
+}
+
+// NewTCPWriter returns a TCPWriter connecting to address.
+func NewTCPWriter(address string, cfg NetConfig) *TCPWriter {
+	return &TCPWriter{conn: newNetConn("tcp", address, cfg)}
+}
+
+// Write sends p, adding a newline if it has none.
+func (w *TCPWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > 0 && p[len(p)-1] != '\n' {
+		p = append(p[:len(p):len(p)], '\n')
+	}
+	if err := w.conn.write(p); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// Sync does nothing, entries are sent on write.
+func (w *TCPWriter) Sync() error {
+	return nil
+}
+
+// Close closes the connection.
+func (w *TCPWriter) Close() error {
+	return w.conn.close()
+}
+
+// SyslogConfig configures a SyslogWriter.
+type SyslogConfig struct {
+	// Network is udp, tcp, unix or unixgram.
+	Network string
+	// Address is the host:port or socket path of the syslog server.
+	Address string
+	// Facility is the syslog facility, 1 (user-level) if 0.
+	Facility int
+	// AppName is the APP-NAME of messages, the executable name if empty.
+	AppName string
+	// Hostname is the HOSTNAME of messages, os.Hostname if empty.
+	Hostname string
+	// LevelKey is the JSON key holding the level of an entry, which sets the
+	// severity of its message. "log_level" if empty.
+	LevelKey string
+	// Net configures the connection.
+	Net NetConfig
+}
+
+// SyslogWriter is a zapcore.WriteSyncer sending entries as RFC 5424 syslog
+// messages. Each write is one message, whose severity is read from the level
+// of JSON entries and is informational otherwise. Over stream networks
+// messages are framed by octet counting as in RFC 6587.
+type SyslogWriter struct {
+	conn     *netConn
+	cfg      SyslogConfig
+	stream   bool
+	levelKey []byte
+	pid      string
+}
+
+// NewSyslogWriter returns a SyslogWriter connecting to the server of cfg.
+func NewSyslogWriter(cfg SyslogConfig) *SyslogWriter {
+	if cfg.Facility == 0 {
+		cfg.Facility = 1
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = filepath.Base(os.Args[0])
+	}
+	if len(cfg.AppName) > maxSyslogAppName {
+		cfg.AppName = cfg.AppName[:maxSyslogAppName]
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if cfg.LevelKey == "" {
+		cfg.LevelKey = defaultKeys().Level
+	}
+	return &SyslogWriter{
+		conn:     newNetConn(cfg.Network, cfg.Address, cfg.Net),
+		cfg:      cfg,
+		stream:   cfg.Network == "tcp" || cfg.Network == "unix",
+		levelKey: []byte(`"` + cfg.LevelKey + `":"`),
+		pid:      strconv.Itoa(os.Getpid()),
+	}
+}
+
+// Write sends p as one message.
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	msg := w.format(time.Now(), p)
+	if err := w.conn.write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// format returns the message of p, framed for stream networks.
+func (w *SyslogWriter) format(t time.Time, p []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(w.cfg.Facility*8 + w.severity(p)))
+	b.WriteString(">1 ")
+	b.WriteString(t.Format("2006-01-02T15:04:05.000000Z07:00"))
+	for _, field := range []string{w.cfg.Hostname, w.cfg.AppName, w.pid} {
+		b.WriteByte(' ')
+		b.WriteString(syslogField(field))
+	}
+	b.WriteString(" - - ")
+	b.Write(bytes.TrimRight(p, "\r\n"))
+	if !w.stream {
+		return b.Bytes()
+	}
+	return append([]byte(strconv.Itoa(b.Len())+" "), b.Bytes()...)
+}
+
+// syslogSeverities are the syslog severities of the levels.
+var syslogSeverities = map[string]int{
+	"debug":  7,
+	"info":   6,
+	"warn":   4,
+	"error":  3,
+	"dpanic": 2,
+	"panic":  2,
+	"fatal":  0,
+}
+
+// severity returns the severity of the level of a JSON entry.
+func (w *SyslogWriter) severity(p []byte) int {
+	if i := bytes.Index(p, w.levelKey); i >= 0 {
+		rest := p[i+len(w.levelKey):]
+		if j := bytes.IndexByte(rest, '"'); j >= 0 {
+			if s, ok := syslogSeverities[string(rest[:j])]; ok {
+				return s
+			}
+		}
+	}
+	return syslogSeverities[zapcore.InfoLevel.String()]
+}
+
+// syslogField returns s as a header field: printable ASCII without spaces,
+// "-" if empty.
+func syslogField(s string) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] > ' ' && s[i] < 127 {
+			b = append(b, s[i])
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// Sync does nothing, messages are sent on write.
+func (w *SyslogWriter) Sync() error {
+	return nil
+}
+
+// Close closes the connection.
+func (w *SyslogWriter) Close() error {
+	return w.conn.close()
+}
@@ -0,0 +1,174 @@
+package log
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var syslogHeader = regexp.MustCompile(`^<(\d+)>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ host app \d+ - - `)
+
+func TestSyslogWriter_UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w := NewSyslogWriter(SyslogConfig{Network: "udp", Address: conn.LocalAddr().String(), AppName: "app", Hostname: "host"})
+	defer w.Close()
+	entry := `{"log_level":"error","message":"failed"}` + "\n"
+	n, err := w.Write([]byte(entry))
+	require.NoError(t, err)
+	assert.Equal(t, len(entry), n)
+
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err = conn.ReadFrom(buf)
+	require.NoError(t, err)
+	msg := string(buf[:n])
+	m := syslogHeader.FindStringSubmatch(msg)
+	require.NotNil(t, m, msg)
+	assert.Equal(t, "11", m[1]) // user facility, error severity
+	assert.True(t, strings.HasSuffix(msg, ` - - {"log_level":"error","message":"failed"}`), msg)
+}
+
+func TestSyslogWriter_Stream(t *testing.T) {
+	for _, network := range []string{"tcp", "unix"} {
+		t.Run(network, func(t *testing.T) {
+			address := "127.0.0.1:0"
+			if network == "unix" {
+				address = filepath.Join(t.TempDir(), "syslog.sock")
+			}
+			ln, err := net.Listen(network, address)
+			require.NoError(t, err)
+			defer ln.Close()
+			received := make(chan string, 1)
+			go func() {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				length, _ := r.ReadString(' ')
+				buf := make([]byte, len(`<14>1 `))
+				r.Read(buf)
+				received <- length + string(buf)
+			}()
+
+			w := NewSyslogWriter(SyslogConfig{Network: network, Address: ln.Addr().String(), AppName: "app", Hostname: "host"})
+			defer w.Close()
+			_, err = w.Write([]byte("plain text\n"))
+			require.NoError(t, err)
+
+			select {
+			case msg := <-received:
+				assert.Regexp(t, `^\d+ <14>1 $`, msg)
+			case <-time.After(5 * time.Second):
+				t.Fatal("no message received")
+			}
+		})
+	}
+}
+
+func TestTCPWriter_Reconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	lines := make(chan string, 16)
+	conns := make(chan net.Conn, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns <- conn
+			go func() {
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					lines <- s.Text()
+				}
+			}()
+		}
+	}()
+
+	w := NewTCPWriter(ln.Addr().String(), NetConfig{MinBackoff: time.Millisecond, MaxBackoff: 10 * time.Millisecond})
+	defer w.Close()
+	n, err := w.Write([]byte(`{"message":"first"}`))
+	require.NoError(t, err)
+	assert.Equal(t, len(`{"message":"first"}`), n, "the added newline must not be counted")
+	assert.Equal(t, `{"message":"first"}`, <-lines)
+
+	(<-conns).Close()
+	assert.Eventually(t, func() bool {
+		w.Write([]byte(`{"message":"again"}` + "\n"))
+		select {
+		case line := <-lines:
+			return line == `{"message":"again"}`
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTCPWriter_Backoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	address := ln.Addr().String()
+	ln.Close()
+
+	w := NewTCPWriter(address, NetConfig{MinBackoff: time.Hour, MaxBackoff: time.Hour})
+	defer w.Close()
+	_, err = w.Write([]byte("first\n"))
+	require.Error(t, err)
+	_, err = w.Write([]byte("second\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unavailable, redialing in")
+}
+
+func TestTCPWriter_Sink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+	}()
+
+	sink, closeSink, err := zap.Open("ndjson+tcp://" + ln.Addr().String())
+	require.NoError(t, err)
+	defer closeSink()
+	_, err = sink.Write([]byte(`{"message":"sink"}` + "\n"))
+	require.NoError(t, err)
+	assert.Equal(t, `{"message":"sink"}`, <-lines)
+}
+
+func TestConfig_Validate_SinkConflict(t *testing.T) {
+	sinkErrors["ndjson+tcp"] = errors.New(`sink factory already registered for scheme "ndjson+tcp"`)
+	defer delete(sinkErrors, "ndjson+tcp")
+
+	cfg := NewTestConfig()
+	cfg.OutputPaths = []string{"ndjson+tcp://localhost:24224"}
+	assert.Error(t, cfg.Validate())
+	cfg.OutputPaths = nil
+	cfg.Outputs = []OutputConfig{{Paths: []string{"NDJSON+TCP://localhost:24224"}}}
+	assert.Error(t, cfg.Validate())
+	cfg.Outputs = []OutputConfig{{Paths: []string{"syslog+udp://localhost:514"}}}
+	assert.NoError(t, cfg.Validate())
+}
@@ -0,0 +1,72 @@
+package log
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultProfile is the environment whose profile is used when none is given.
+const DefaultProfile = "prod"
+
+// Profile returns the logger config of an environment.
+type Profile func() Config
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = map[string]Profile{
+		"prod":    NewProductionConfig,
+		"int":     NewProductionConfig,
+		"sandbox": NewProductionConfig,
+		"dev":     NewDevelopmentConfig,
+		"test":    NewTestConfig,
+	}
+	defaultProfile = DefaultProfile
+)
+
+// RegisterProfile registers the profile of env, replacing any previous one.
+func RegisterProfile(env string, p Profile) {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	profiles[env] = p
+}
+
+// SetDefaultProfile makes the profile of env the one used when no
+// environment is given.
+func SetDefaultProfile(env string) error {
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	if _, ok := profiles[env]; !ok {
+		return unknownProfile(env)
+	}
+	defaultProfile = env
+	return nil
+}
+
+// ProfileConfig returns the config of the profile of env, or of the default
+// profile if env is empty.
+func ProfileConfig(env string) (Config, error) {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	if env == "" {
+		env = defaultProfile
+	}
+	p, ok := profiles[env]
+	if !ok {
+		return Config{}, unknownProfile(env)
+	}
+	return p(), nil
+}
+
+// unknownProfile returns the error for env, listing the known environments.
+// It must be called with profilesMu held.
+func unknownProfile(env string) error {
+	envs := make([]string, 0, len(profiles))
+	for name := range profiles {
+		envs = append(envs, name)
+	}
+	sort.Strings(envs)
+	return errors.Errorf("log: unknown environment %q, expected one of %s", env, strings.Join(envs, ", "))
+}
@@ -0,0 +1,38 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfileConfig(t *testing.T) {
+	cfg, err := ProfileConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, NewProductionConfig(), cfg)
+
+	cfg, err = ProfileConfig("test")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.OutputPaths)
+
+	RegisterProfile("staging", func() Config {
+		cfg := NewProductionConfig()
+		cfg.Level = "debug"
+		return cfg
+	})
+	defer func() {
+		profilesMu.Lock()
+		delete(profiles, "staging")
+		defaultProfile = DefaultProfile
+		profilesMu.Unlock()
+	}()
+	require.NoError(t, SetDefaultProfile("staging"))
+	cfg, err = ProfileConfig("")
+	require.NoError(t, err)
+	assert.Equal(t, "debug", cfg.Level)
+
+	_, err = ProfileConfig("qa")
+	assert.EqualError(t, err, `log: unknown environment "qa", expected one of dev, int, prod, sandbox, staging, test`)
+	assert.Error(t, SetDefaultProfile("qa"))
+}
@@ -0,0 +1,119 @@
+package log
+
+import (
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// rateLimitBuckets is the number of token buckets shared by all keys. Keys
+// hashing to the same bucket share its tokens, like zap's sampler does.
+const rateLimitBuckets = 4096
+
+// RateLimitConfig limits the entries written per key with a token bucket
+// that holds Burst tokens and refills Rate tokens per second.
+type RateLimitConfig struct {
+	Rate  float64
+	Burst int
+	// KeyField is the field whose value keys the entries. Entries without
+	// it, or all entries if it is empty, are keyed by level and message.
+	KeyField string
+}
+
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket if there is one. Concurrent writers
+// pass entry times out of order, an earlier now refills nothing.
+func (b *tokenBucket) allow(now time.Time, rate float64, burst int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+		b.last = now
+	} else if now.After(b.last) {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type rateLimitCore struct {
+	zapcore.Core
+	cfg     RateLimitConfig
+	buckets *[rateLimitBuckets]tokenBucket
+	drops   *dropCounter
+	// key is the value of cfg.KeyField added with With, if any.
+	key string
+}
+
+// newRateLimitCore drops the entries of core exceeding the rate limit of
+// their key and counts them into drops.
+func newRateLimitCore(core zapcore.Core, cfg RateLimitConfig, drops *dropCounter) zapcore.Core {
+	return &rateLimitCore{
+		Core:    core,
+		cfg:     cfg,
+		buckets: &[rateLimitBuckets]tokenBucket{},
+		drops:   drops,
+	}
+}
+
+func (c *rateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	if key, ok := c.keyOf(fields); ok {
+		clone.key = key
+	}
+	return &clone
+}
+
+func (c *rateLimitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *rateLimitCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	key, ok := c.keyOf(fields)
+	if !ok {
+		key = c.key
+	}
+	if key == "" {
+		key = ent.Level.String() + ent.Message
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	bucket := &c.buckets[h.Sum32()%rateLimitBuckets]
+	if !bucket.allow(ent.Time, c.cfg.Rate, c.cfg.Burst) {
+		c.drops.inc(ent, dropReasonRateLimit)
+		return nil
+	}
+	return c.Core.Write(ent, fields)
+}
+
+// keyOf returns the value of the key field in fields.
+func (c *rateLimitCore) keyOf(fields []zapcore.Field) (string, bool) {
+	if c.cfg.KeyField == "" {
+		return "", false
+	}
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == c.cfg.KeyField && fields[i].Type == zapcore.StringType {
+			return fields[i].String, true
+		}
+	}
+	return "", false
+}
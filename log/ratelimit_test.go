@@ -0,0 +1,52 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestTokenBucket(t *testing.T) {
+	var b tokenBucket
+	now := time.Now()
+	assert.True(t, b.allow(now, 1, 2))
+	assert.True(t, b.allow(now, 1, 2))
+	assert.False(t, b.allow(now, 1, 2))
+	assert.False(t, b.allow(now.Add(500*time.Millisecond), 1, 2))
+	assert.True(t, b.allow(now.Add(time.Second), 1, 2))
+
+	// An earlier entry neither removes tokens nor moves the refill back.
+	assert.True(t, b.allow(now.Add(10*time.Second), 1, 2))
+	assert.True(t, b.allow(now, 1, 2))
+	assert.False(t, b.allow(now.Add(10*time.Second), 1, 2))
+}
+
+func TestRateLimitCore(t *testing.T) {
+	scope := tally.NewTestScope("", nil)
+	drops := newDropCounter()
+	drops.report(scope, "dropped_count")
+	observed, logs := observer.New(zap.DebugLevel)
+	logger := zap.New(newRateLimitCore(observed, RateLimitConfig{Rate: 0.001, Burst: 2, KeyField: "tenant"}, drops))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("by message")
+		logger.Info("by field", zap.String("tenant", "a"))
+		logger.With(zap.String("tenant", "b")).Info("by field")
+	}
+
+	require.Equal(t, 6, logs.Len())
+	assert.Len(t, logs.FilterMessage("by message").All(), 2)
+	assert.Len(t, logs.FilterField(zap.String("tenant", "a")).All(), 2)
+	assert.EqualValues(t, 3, counterValue(scope, "dropped_count", map[string]string{"reason": "rate_limit"}))
+}
+
+func TestRateLimitCore_Disabled(t *testing.T) {
+	logger := zap.New(newRateLimitCore(zapcore.NewNopCore(), RateLimitConfig{Rate: 1, Burst: 1}, nil))
+	assert.Nil(t, logger.Check(zap.InfoLevel, "disabled"))
+}
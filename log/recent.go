@@ -0,0 +1,178 @@
+package log
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap/zapcore"
+)
+
+// RecentEntry is an entry kept by RecentEntries.
+type RecentEntry struct {
+	zapcore.Entry
+	Context []zapcore.Field
+}
+
+// RecentFilter selects recent entries. The zero value selects all of them.
+type RecentFilter struct {
+	// Levels selects the levels, all of them if nil.
+	Levels zapcore.LevelEnabler
+	// Logger selects the entries of the named logger and its descendants.
+	Logger string
+	// Contains selects the entries whose encoded form contains it.
+	Contains string
+}
+
+func (f RecentFilter) match(e RecentEntry) bool {
+	if f.Levels != nil && !f.Levels.Enabled(e.Level) {
+		return false
+	}
+	if f.Logger != "" && e.LoggerName != f.Logger && !strings.HasPrefix(e.LoggerName, f.Logger+".") {
+		return false
+	}
+	return true
+}
+
+// RecentEntries keeps the last entries logged at all levels, regardless of
+// the level of the logger, see Config.RecentEntries.
+type RecentEntries struct {
+	mu      sync.Mutex
+	entries []RecentEntry
+	next    int
+	full    bool
+}
+
+// NewRecentEntries keeps the last size entries.
+func NewRecentEntries(size int) *RecentEntries {
+	return &RecentEntries{entries: make([]RecentEntry, size)}
+}
+
+func (r *RecentEntries) add(e RecentEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Entries returns the entries selected by filter, oldest first. The
+// Contains filter is ignored, it applies to the encoded entries of Write.
+func (r *RecentEntries) Entries(filter RecentFilter) []RecentEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var all []RecentEntry
+	if r.full {
+		all = append(all, r.entries[r.next:]...)
+	}
+	all = append(all, r.entries[:r.next]...)
+
+	selected := all[:0]
+	for _, e := range all {
+		if filter.match(e) {
+			selected = append(selected, e)
+		}
+	}
+	return selected
+}
+
+// Write writes the entries selected by filter, oldest first, encoded in
+// format, FormatJSON or FormatConsole without colors.
+func (r *RecentEntries) Write(w io.Writer, format string, filter RecentFilter) error {
+	var enc zapcore.Encoder
+	switch format {
+	case FormatJSON:
+		enc = zapcore.NewJSONEncoder(recentEncoderConfig())
+	case FormatConsole:
+		enc = zapcore.NewConsoleEncoder(recentEncoderConfig())
+	default:
+		return errors.Errorf("log: unknown format %q, expected one of %s, %s", format, FormatJSON, FormatConsole)
+	}
+	for _, e := range r.Entries(filter) {
+		buf, err := enc.EncodeEntry(e.Entry, e.Context)
+		if err != nil {
+			return errors.Wrap(err, "log: encoding recent entry")
+		}
+		if filter.Contains == "" || strings.Contains(buf.String(), filter.Contains) {
+			_, err = w.Write(buf.Bytes())
+		}
+		buf.Free()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func recentEncoderConfig() zapcore.EncoderConfig {
+	keys := defaultKeys()
+	return zapcore.EncoderConfig{
+		TimeKey:        keys.Time,
+		LevelKey:       keys.Level,
+		NameKey:        keys.Name,
+		CallerKey:      keys.Caller,
+		MessageKey:     keys.Message,
+		StacktraceKey:  keys.Stacktrace,
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.TimeEncoderOfLayout(DefaultTimeFormat),
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+}
+
+// recentCore captures all entries into RecentEntries on top of the core
+// writing to the outputs, which decides on its own what it writes. The
+// captured entries are redacted like the written ones if r is not nil.
+type recentCore struct {
+	zapcore.Core
+	recent  *RecentEntries
+	r       *redactor
+	context []zapcore.Field
+}
+
+func newRecentCore(core zapcore.Core, recent *RecentEntries, r *redactor) *recentCore {
+	return &recentCore{Core: core, recent: recent, r: r}
+}
+
+// Enabled reports true, all levels are captured.
+func (c *recentCore) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (c *recentCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.Core = c.Core.With(fields)
+	if c.r != nil {
+		fields = c.r.fields(fields)
+	}
+	clone.context = append(c.context[:len(c.context):len(c.context)], fields...)
+	return &clone
+}
+
+func (c *recentCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return c.Core.Check(ent, ce).AddCore(ent, c)
+}
+
+// Write captures the entry. The wrapped core registered itself in Check and
+// writes the entry on its own.
+func (c *recentCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.r != nil {
+		ent.Message = c.r.maskString(ent.Message)
+		fields = c.r.fields(fields)
+	}
+	all := make([]zapcore.Field, 0, len(c.context)+len(fields))
+	all = append(append(all, c.context...), fields...)
+	c.recent.add(RecentEntry{Entry: ent, Context: all})
+	return nil
+}
+
+// wrap returns a copy of the core with fn applied to the wrapped core.
+func (c *recentCore) wrap(fn func(zapcore.Core) zapcore.Core) zapcore.Core {
+	clone := *c
+	clone.Core = fn(c.Core)
+	return &clone
+}
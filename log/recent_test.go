@@ -0,0 +1,68 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/uber-go/tally"
+	"go.uber.org/zap"
+)
+
+func TestRecentEntries(t *testing.T) {
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = nil
+	cfg.Sampling = nil
+	cfg.RecentEntries = 3
+	logger, err := NewLogger(cfg)
+	require.NoError(t, err)
+	scope := tally.NewTestScope("", nil)
+	logger = logger.WithMetrics(scope)
+
+	logger.Zap().Info("dropped from the ring")
+	logger.Named("db").Zap().Debug("query", zap.String("table", "users"))
+	logger.Named("db").Named("pool").Zap().Warn("slow")
+	logger.Named("http").Zap().Error("failed")
+
+	entries := logger.Recent().Entries(RecentFilter{})
+	require.Len(t, entries, 3)
+	assert.Equal(t, "query", entries[0].Message)
+	assert.Equal(t, []zap.Field{zap.String("table", "users")}, entries[0].Context)
+	assert.Equal(t, int64(0), counterValue(scope, "debug_count", nil))
+	assert.Equal(t, int64(1), counterValue(scope, "warn_count", nil))
+
+	entries = logger.Recent().Entries(RecentFilter{Logger: "db", Levels: zap.WarnLevel})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "db.pool", entries[0].LoggerName)
+
+	var buf bytes.Buffer
+	require.NoError(t, logger.Recent().Write(&buf, FormatJSON, RecentFilter{Contains: "users"}))
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 1)
+	assert.Contains(t, lines[0], `"log_level":"debug"`)
+
+	assert.Error(t, logger.Recent().Write(&buf, "xml", RecentFilter{}))
+}
+
+func TestRecentEntries_Redact(t *testing.T) {
+	cfg := NewProductionConfig()
+	cfg.OutputPaths = nil
+	cfg.Sampling = nil
+	cfg.RecentEntries = 10
+	cfg.Redact = NewRedactConfig()
+	logger, err := NewLogger(cfg)
+	require.NoError(t, err)
+
+	logger.Zap().With(zap.String("password", "hunter2")).Info("login bob@example.com", zap.String("token", "abc"))
+
+	var buf bytes.Buffer
+	require.NoError(t, logger.Recent().Write(&buf, FormatJSON, RecentFilter{}))
+	assert.Contains(t, buf.String(), `"message":"login [REDACTED]"`)
+	assert.Contains(t, buf.String(), `"password":"[REDACTED]"`)
+	assert.Contains(t, buf.String(), `"token":"[REDACTED]"`)
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.NotContains(t, buf.String(), "abc")
+	assert.NotContains(t, buf.String(), "bob@example.com")
+}
@@ -0,0 +1,403 @@
+package log
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	// DefaultRedactMask replaces redacted values when RedactConfig.Mask is empty.
+	DefaultRedactMask = "[REDACTED]"
+
+	// RedactCreditCards matches credit card numbers, optionally grouped by spaces or dashes.
+	RedactCreditCards = `\b\d(?:[ -]?\d){12,18}\b`
+	// RedactBearerTokens matches bearer tokens as sent in Authorization headers.
+	RedactBearerTokens = `(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`
+	// RedactEmails matches email addresses.
+	RedactEmails = `[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`
+)
+
+// RedactConfig removes sensitive data from entries before they are encoded.
+//
+// Values of fields with matching keys are replaced entirely, in nested
+// objects and arrays too. Matches of the patterns are masked in messages and
+// string values. Values logged with zap.Any that fall back to reflection are
+// only redacted by key.
+type RedactConfig struct {
+	// Keys are field keys matched case-insensitively, either exactly or as
+	// path.Match globs such as "*token*".
+	Keys []string
+	// Patterns are regular expressions masked in messages and string values.
+	Patterns []string
+	// Mask replaces redacted values, DefaultRedactMask if empty.
+	Mask string
+}
+
+// NewRedactConfig returns a config redacting common credential keys,
+// credit card numbers, bearer tokens and emails.
+func NewRedactConfig() *RedactConfig {
+	return &RedactConfig{
+		Keys:     []string{"password", "passwd", "secret", "*token*", "authorization", "cookie", "api_key", "apikey"},
+		Patterns: []string{RedactCreditCards, RedactBearerTokens, RedactEmails},
+	}
+}
+
+// redactor is the compiled form of a RedactConfig.
+type redactor struct {
+	keys     map[string]struct{}
+	globs    []string
+	patterns []*regexp.Regexp
+	mask     string
+}
+
+func newRedactor(cfg RedactConfig) (*redactor, error) {
+	r := &redactor{
+		keys: make(map[string]struct{}, len(cfg.Keys)),
+		mask: cfg.Mask,
+	}
+	if r.mask == "" {
+		r.mask = DefaultRedactMask
+	}
+	for _, key := range cfg.Keys {
+		key = strings.ToLower(key)
+		if !strings.ContainsAny(key, `*?[\`) {
+			r.keys[key] = struct{}{}
+			continue
+		}
+		if _, err := path.Match(key, ""); err != nil {
+			return nil, errors.Wrapf(err, "log: redact key %q", key)
+		}
+		r.globs = append(r.globs, key)
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "log: redact pattern %q", pattern)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// redactKey reports whether the value under key must be replaced.
+func (r *redactor) redactKey(key string) bool {
+	if len(r.keys) == 0 && len(r.globs) == 0 {
+		return false
+	}
+	key = strings.ToLower(key)
+	if _, ok := r.keys[key]; ok {
+		return true
+	}
+	for _, glob := range r.globs {
+		if ok, _ := path.Match(glob, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// maskString masks the matches of the patterns in s.
+func (r *redactor) maskString(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllLiteralString(s, r.mask)
+	}
+	return s
+}
+
+// fields returns fields with sensitive values redacted. The slice is only
+// copied if a field changes.
+func (r *redactor) fields(fields []zapcore.Field) []zapcore.Field {
+	out, copied := fields, false
+	for i, f := range fields {
+		redacted, changed := r.field(f)
+		if !changed {
+			continue
+		}
+		if !copied {
+			out = make([]zapcore.Field, len(fields))
+			copy(out, fields)
+			copied = true
+		}
+		out[i] = redacted
+	}
+	return out
+}
+
+func (r *redactor) field(f zapcore.Field) (zapcore.Field, bool) {
+	switch f.Type {
+	case zapcore.NamespaceType, zapcore.SkipType:
+		return f, false
+	}
+	if r.redactKey(f.Key) {
+		return zap.String(f.Key, r.mask), true
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		if s := r.maskString(f.String); s != f.String {
+			return zap.String(f.Key, s), true
+		}
+	case zapcore.ByteStringType:
+		b := f.Interface.([]byte)
+		if s := r.maskString(string(b)); s != string(b) {
+			return zap.String(f.Key, s), true
+		}
+	case zapcore.ErrorType, zapcore.StringerType:
+		if err, changed := r.errorValue(f.Interface); changed {
+			return zap.NamedError(f.Key, err), true
+		}
+		if len(r.patterns) == 0 {
+			return f, false
+		}
+		var s string
+		if err, ok := f.Interface.(error); ok {
+			s = err.Error()
+		} else if st, ok := f.Interface.(fmt.Stringer); ok {
+			s = st.String()
+		}
+		if masked := r.maskString(s); masked != s {
+			return zap.String(f.Key, masked), true
+		}
+	case zapcore.ObjectMarshalerType:
+		return zap.Object(f.Key, redactedObject{obj: f.Interface.(zapcore.ObjectMarshaler), r: r}), true
+	case zapcore.ArrayMarshalerType:
+		return zap.Array(f.Key, redactedArray{arr: f.Interface.(zapcore.ArrayMarshaler), r: r}), true
+	}
+	return f, false
+}
+
+// redactCore redacts the fields and messages of the entries it writes.
+type redactCore struct {
+	zapcore.Core
+	r *redactor
+}
+
+func newRedactCore(core zapcore.Core, r *redactor) zapcore.Core {
+	return &redactCore{Core: core, r: r}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.r.fields(fields)), r: c.r}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.r.maskString(ent.Message)
+	return c.Core.Write(ent, c.r.fields(fields))
+}
+
+type redactedObject struct {
+	obj zapcore.ObjectMarshaler
+	r   *redactor
+}
+
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	return o.obj.MarshalLogObject(&redactObjectEncoder{ObjectEncoder: enc, r: o.r})
+}
+
+type redactedArray struct {
+	arr zapcore.ArrayMarshaler
+	r   *redactor
+}
+
+func (a redactedArray) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	return a.arr.MarshalLogArray(&redactArrayEncoder{ArrayEncoder: enc, r: a.r})
+}
+
+// redactObjectEncoder redacts the values added to an object.
+type redactObjectEncoder struct {
+	zapcore.ObjectEncoder
+	r *redactor
+}
+
+// masked adds the mask instead of the value if key must be redacted.
+func (e *redactObjectEncoder) masked(key string) bool {
+	if e.r.redactKey(key) {
+		e.ObjectEncoder.AddString(key, e.r.mask)
+		return true
+	}
+	return false
+}
+
+func (e *redactObjectEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	if e.masked(key) {
+		return nil
+	}
+	return e.ObjectEncoder.AddArray(key, redactedArray{arr: arr, r: e.r})
+}
+
+func (e *redactObjectEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	if e.masked(key) {
+		return nil
+	}
+	return e.ObjectEncoder.AddObject(key, redactedObject{obj: obj, r: e.r})
+}
+
+func (e *redactObjectEncoder) AddBinary(key string, value []byte) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddBinary(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddByteString(key string, value []byte) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddString(key, e.r.maskString(string(value)))
+	}
+}
+
+func (e *redactObjectEncoder) AddBool(key string, value bool) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddBool(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddComplex128(key string, value complex128) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddComplex128(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddComplex64(key string, value complex64) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddComplex64(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddDuration(key string, value time.Duration) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddDuration(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddFloat64(key string, value float64) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddFloat64(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddFloat32(key string, value float32) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddFloat32(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt(key string, value int) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddInt(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt64(key string, value int64) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddInt64(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt32(key string, value int32) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddInt32(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt16(key string, value int16) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddInt16(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddInt8(key string, value int8) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddInt8(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddString(key, value string) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddString(key, e.r.maskString(value))
+	}
+}
+
+func (e *redactObjectEncoder) AddTime(key string, value time.Time) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddTime(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint(key string, value uint) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddUint(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint64(key string, value uint64) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddUint64(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint32(key string, value uint32) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddUint32(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint16(key string, value uint16) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddUint16(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddUint8(key string, value uint8) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddUint8(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddUintptr(key string, value uintptr) {
+	if !e.masked(key) {
+		e.ObjectEncoder.AddUintptr(key, value)
+	}
+}
+
+func (e *redactObjectEncoder) AddReflected(key string, value interface{}) error {
+	if e.masked(key) {
+		return nil
+	}
+	return e.ObjectEncoder.AddReflected(key, value)
+}
+
+// redactArrayEncoder masks the strings appended to an array and redacts
+// nested objects and arrays.
+type redactArrayEncoder struct {
+	zapcore.ArrayEncoder
+	r *redactor
+}
+
+func (e *redactArrayEncoder) AppendString(value string) {
+	e.ArrayEncoder.AppendString(e.r.maskString(value))
+}
+
+func (e *redactArrayEncoder) AppendByteString(value []byte) {
+	e.ArrayEncoder.AppendString(e.r.maskString(string(value)))
+}
+
+func (e *redactArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	return e.ArrayEncoder.AppendArray(redactedArray{arr: arr, r: e.r})
+}
+
+func (e *redactArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	return e.ArrayEncoder.AppendObject(redactedObject{obj: obj, r: e.r})
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type credentials struct {
+	User     string
+	Password string
+	Headers  []string
+}
+
+func (c credentials) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("user", c.User)
+	enc.AddString("password", c.Password)
+	return enc.AddArray("headers", zapcore.ArrayMarshalerFunc(func(arr zapcore.ArrayEncoder) error {
+		for _, header := range c.Headers {
+			arr.AppendString(header)
+		}
+		return nil
+	}))
+}
+
+func TestRedactCore(t *testing.T) {
+	r, err := newRedactor(*NewRedactConfig())
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	enc := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "message"})
+	logger := &Logger{
+		zap:    zap.New(newRedactCore(zapcore.NewCore(enc, zapcore.AddSync(buf), zap.DebugLevel), r)),
+		levels: NewLevelController(zap.NewAtomicLevel()),
+	}
+
+	ctx := AddFieldToCtx(context.Background(), zap.String("access_token", "abc"))
+	logger.FromContext(ctx).Info("sent to john@example.com",
+		zap.String("card", "4111 1111 1111 1111"),
+		zap.String("header", "Bearer eyJhbGciOi.J9"),
+		zap.Int("PASSWORD", 1234),
+		zap.Object("creds", credentials{User: "jane@example.com", Password: "hunter2", Headers: []string{"Bearer xyz"}}),
+		zap.String("safe", "value"),
+	)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "sent to [REDACTED]", entry["message"])
+	assert.Equal(t, "[REDACTED]", entry["access_token"])
+	assert.Equal(t, "[REDACTED]", entry["card"])
+	assert.Equal(t, "[REDACTED]", entry["header"])
+	assert.Equal(t, "[REDACTED]", entry["PASSWORD"])
+	assert.Equal(t, map[string]interface{}{
+		"user":     "[REDACTED]",
+		"password": "[REDACTED]",
+		"headers":  []interface{}{"[REDACTED]"},
+	}, entry["creds"])
+	assert.Equal(t, "value", entry["safe"])
+}
+
+func TestRedactConfig_Invalid(t *testing.T) {
+	cfg := NewTestConfig()
+	cfg.Redact = &RedactConfig{Patterns: []string{"("}}
+	assert.Error(t, cfg.Validate())
+
+	cfg.Redact = &RedactConfig{Keys: []string{"[token"}}
+	assert.Error(t, cfg.Validate())
+}
@@ -0,0 +1,367 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// backupTimeFormat is the layout of the timestamp in backup file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotationConfig describes how files written by the logger are rotated.
+// A rotated file is renamed to a backup next to it with the rotation time
+// inserted before the extension, e.g. app-2006-01-02T15-04-05.000.log.
+type RotationConfig struct {
+	// MaxSize is the size in bytes after which the file is rotated, 0 for no limit.
+	MaxSize int64
+	// Every is the age after which the file is rotated, 0 for no limit.
+	Every time.Duration
+	// MaxBackups is the number of backups to keep, 0 to keep all of them.
+	MaxBackups int
+	// MaxAge is the age after which backups are removed, 0 to keep them.
+	MaxAge time.Duration
+	// Compress gzips the backups.
+	Compress bool
+	// ReopenOnSighup reopens the file on SIGHUP, for rotation by an external
+	// tool such as logrotate.
+	ReopenOnSighup bool
+}
+
+// RotatingFile is a zapcore.WriteSyncer writing to a file that is rotated by
+// size and age. Backups are compressed and removed in the background.
+type RotatingFile struct {
+	path string
+	cfg  RotationConfig
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+
+	mill chan struct{}
+	stop chan struct{}
+	done sync.WaitGroup
+}
+
+// NewRotatingFile opens the file at path for appending, creating it if needed.
+func NewRotatingFile(path string, cfg RotationConfig) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path: path,
+		cfg:  cfg,
+		mill: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	f.done.Add(1)
+	go f.runMill()
+	if cfg.ReopenOnSighup {
+		f.ReopenOnSignal(syscall.SIGHUP)
+	}
+	return f, nil
+}
+
+// Write implements io.Writer, rotating the file first if p would exceed
+// MaxSize or the file is older than Every. If the rotation fails, p is
+// written to the current file and the error is returned.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return 0, errors.Errorf("log: write to closed file %s", f.path)
+	}
+
+	var rotateErr error
+	if f.size > 0 && ((f.cfg.MaxSize > 0 && f.size+int64(len(p)) > f.cfg.MaxSize) ||
+		(f.cfg.Every > 0 && time.Since(f.opened) >= f.cfg.Every)) {
+		rotateErr = f.rotateLocked()
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return n, err
+}
+
+// Sync commits the file to stable storage.
+func (f *RotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	return f.file.Sync()
+}
+
+// Rotate renames the file to a backup and opens a new one.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return errors.Errorf("log: rotate of closed file %s", f.path)
+	}
+	return f.rotateLocked()
+}
+
+// Reopen opens the file again by path, picking up a new file if it was
+// moved away by an external tool, and closes the previous one. If opening
+// fails, the previous file is kept.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	return f.open()
+}
+
+// ReopenOnSignal reopens the file whenever one of the signals is received,
+// until the file is closed.
+func (f *RotatingFile) ReopenOnSignal(sigs ...os.Signal) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	f.done.Add(1)
+	go func() {
+		defer f.done.Done()
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-f.stop:
+				return
+			case <-ch:
+				if err := f.Reopen(); err != nil {
+					// There is no logger to report to, the file is what broke.
+					os.Stderr.WriteString(err.Error() + "\n")
+				}
+			}
+		}
+	}()
+}
+
+// Close closes the file and waits for pending compression and cleanup.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		return nil
+	}
+	f.closed = true
+	close(f.stop)
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.done.Wait()
+	return err
+}
+
+// open opens the file for appending and closes the previous one, which is
+// kept if opening fails. It must be called with mu held.
+func (f *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0755); err != nil {
+		return errors.Wrapf(err, "log: creating directory of %s", f.path)
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "log: opening %s", f.path)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return errors.Wrapf(err, "log: opening %s", f.path)
+	}
+	if f.file != nil {
+		// The entries are written already, the handle is of no use anymore.
+		f.file.Close()
+	}
+	f.file = file
+	f.size = info.Size()
+	f.opened = time.Now()
+	return nil
+}
+
+// rotateLocked renames the file to a backup and opens a new one. If either
+// fails, the current file is kept.
+func (f *RotatingFile) rotateLocked() error {
+	backup := f.freeBackupName(time.Now())
+	if err := os.Rename(f.path, backup); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "log: rotating %s", f.path)
+	}
+	if err := f.open(); err != nil {
+		// Best effort, the current file is written to under either name.
+		os.Rename(backup, f.path)
+		return err
+	}
+	select {
+	case f.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// backupName returns the name of the backup of a file rotated at t.
+func (f *RotatingFile) backupName(t time.Time) string {
+	prefix, ext := f.backupPattern()
+	return prefix + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// freeBackupName returns the name of the backup of a file rotated at t,
+// moved forward by a millisecond as long as a backup with that name exists,
+// so that rotations within the same millisecond do not overwrite each other.
+func (f *RotatingFile) freeBackupName(t time.Time) string {
+	for {
+		name := f.backupName(t)
+		if !fileExists(name) && !fileExists(name+".gz") {
+			return name
+		}
+		t = t.Add(time.Millisecond)
+	}
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// backupPattern returns the parts of the backup names around the timestamp.
+func (f *RotatingFile) backupPattern() (prefix, ext string) {
+	ext = filepath.Ext(f.path)
+	return strings.TrimSuffix(f.path, ext) + "-", ext
+}
+
+// runMill compresses and removes backups after each rotation.
+func (f *RotatingFile) runMill() {
+	defer f.done.Done()
+	for {
+		select {
+		case <-f.stop:
+			return
+		case <-f.mill:
+			if err := f.millBackups(); err != nil {
+				os.Stderr.WriteString(err.Error() + "\n")
+			}
+		}
+	}
+}
+
+type backup struct {
+	path string
+	time time.Time
+}
+
+// millBackups removes the backups beyond MaxBackups and older than MaxAge,
+// then compresses the remaining ones.
+func (f *RotatingFile) millBackups() error {
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+
+	var remove []backup
+	if f.cfg.MaxBackups > 0 && len(backups) > f.cfg.MaxBackups {
+		remove = append(remove, backups[f.cfg.MaxBackups:]...)
+		backups = backups[:f.cfg.MaxBackups]
+	}
+	if f.cfg.MaxAge > 0 {
+		cutoff := time.Now().Add(-f.cfg.MaxAge)
+		kept := backups[:0]
+		for _, b := range backups {
+			if b.time.Before(cutoff) {
+				remove = append(remove, b)
+			} else {
+				kept = append(kept, b)
+			}
+		}
+		backups = kept
+	}
+	for _, b := range remove {
+		if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "log: removing backup %s", b.path)
+		}
+	}
+
+	if f.cfg.Compress {
+		for _, b := range backups {
+			if strings.HasSuffix(b.path, ".gz") {
+				continue
+			}
+			if err := compressFile(b.path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// backups lists the backups of the file from newest to oldest.
+func (f *RotatingFile) backups() ([]backup, error) {
+	prefix, ext := f.backupPattern()
+	matches, err := filepath.Glob(prefix + "*")
+	if err != nil {
+		return nil, errors.Wrap(err, "log: listing backups")
+	}
+	var backups []backup
+	for _, path := range matches {
+		stamp := strings.TrimPrefix(strings.TrimSuffix(path, ".gz"), prefix)
+		if !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(stamp, ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: path, time: t})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].time.After(backups[j].time) })
+	return backups, nil
+}
+
+// compressFile gzips the file at path into path.gz and removes the original.
+func compressFile(path string) (err error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return errors.Wrapf(err, "log: compressing %s", path)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.Wrapf(err, "log: compressing %s", path)
+	}
+	defer func() {
+		if err != nil {
+			dst.Close()
+			os.Remove(path + ".gz")
+		}
+	}()
+
+	gz := gzip.NewWriter(dst)
+	if _, err = io.Copy(gz, src); err != nil {
+		return errors.Wrapf(err, "log: compressing %s", path)
+	}
+	if err = gz.Close(); err != nil {
+		return errors.Wrapf(err, "log: compressing %s", path)
+	}
+	if err = dst.Close(); err != nil {
+		return errors.Wrapf(err, "log: compressing %s", path)
+	}
+	src.Close()
+	return os.Remove(path)
+}
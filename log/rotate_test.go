@@ -0,0 +1,119 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile_MaxSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path, RotationConfig{MaxSize: 10, MaxBackups: 2, Compress: true})
+	require.NoError(t, err)
+
+	for i := 0; i < 4; i++ {
+		_, err := f.Write([]byte("0123456789"))
+		require.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		backups, _ := filepath.Glob(filepath.Join(dir, "app-*"))
+		compressed, _ := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+		return len(backups) == 2 && len(compressed) == 2
+	}, time.Second, 5*time.Millisecond)
+	require.NoError(t, f.Close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+}
+
+func TestRotatingFile_SameMillisecond(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path, RotationConfig{})
+	require.NoError(t, err)
+	defer f.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := f.Write([]byte{'0' + byte(i)})
+		require.NoError(t, err)
+		require.NoError(t, f.Rotate())
+	}
+	backups, err := f.backups()
+	require.NoError(t, err)
+	require.Len(t, backups, 5)
+	for i, b := range backups {
+		content, err := os.ReadFile(b.path)
+		require.NoError(t, err)
+		assert.Equal(t, string([]byte{'4' - byte(i)}), string(content))
+	}
+}
+
+func TestRotatingFile_Reopen(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	f, err := NewRotatingFile(path, RotationConfig{})
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("before\n"))
+	require.NoError(t, err)
+	require.NoError(t, os.Rename(path, path+".1"))
+	require.NoError(t, f.Reopen())
+	_, err = f.Write([]byte("after\n"))
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "after\n", string(content))
+}
+
+func TestRotatingFile_Failures(t *testing.T) {
+	dir := t.TempDir()
+	// The backup names are too long for the file system, renaming fails.
+	path := filepath.Join(dir, strings.Repeat("a", 240)+".log")
+	f, err := NewRotatingFile(path, RotationConfig{MaxSize: 4})
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("one\n"))
+	require.NoError(t, err)
+	n, err := f.Write([]byte("two\n"))
+	assert.Error(t, err)
+	assert.Equal(t, 4, n)
+	assert.Error(t, f.Rotate())
+
+	require.NoError(t, os.Remove(path))
+	require.NoError(t, os.Mkdir(path, 0755))
+	assert.Error(t, f.Reopen())
+	_, err = f.Write([]byte("three\n"))
+	assert.Error(t, err)
+
+	// The file was moved away, the rotation opens a new one.
+	require.NoError(t, os.Remove(path))
+	_, err = f.Write([]byte("four\n"))
+	require.NoError(t, err)
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "four\n", string(content))
+}
+
+func TestFilePath(t *testing.T) {
+	for output, want := range map[string]string{
+		"stderr":              "",
+		"/var/log/app.log":    "/var/log/app.log",
+		"app.log":             "app.log",
+		"file:///tmp/app.log": "/tmp/app.log",
+		"udp://localhost:514": "",
+	} {
+		path, ok := filePath(output)
+		assert.Equal(t, want != "", ok, output)
+		assert.Equal(t, want, path, output)
+	}
+}
@@ -0,0 +1,168 @@
+package log
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// SetSlogDefault makes a handler from NewSlogHandler the default of the
+// log/slog package and returns a function restoring the previous default.
+func SetSlogDefault() func() {
+	prev := slog.Default()
+	slog.SetDefault(slog.New(NewSlogHandler()))
+	return func() {
+		slog.SetDefault(prev)
+	}
+}
+
+// NewSlogHandler returns a slog.Handler writing to the global logger,
+// whichever logger is global at the time of each record, with the fields of
+// the record context, see FromContext. Groups are written as nested objects.
+func NewSlogHandler() slog.Handler {
+	return &slogHandler{}
+}
+
+type slogHandler struct {
+	// fields are the attrs added with WithAttrs, after the namespaces of
+	// their groups.
+	fields []zap.Field
+	// groups are the groups opened after the last WithAttrs, which are
+	// omitted unless a record has attrs.
+	groups []string
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return L().zap.Core().Enabled(zapLevel(level))
+}
+
+func (h *slogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ce := L().FromContext(ctx).Check(zapLevel(r.Level), r.Message)
+	if ce == nil {
+		return nil
+	}
+	if !r.Time.IsZero() {
+		ce.Time = r.Time
+	}
+	if ce.Caller.Defined && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ce.Caller = zapcore.NewEntryCaller(frame.PC, frame.File, frame.Line, true)
+		ce.Caller.Function = frame.Function
+	}
+
+	fields := h.fields
+	if r.NumAttrs() > 0 {
+		fields = make([]zap.Field, 0, len(h.fields)+len(h.groups)+r.NumAttrs())
+		fields = append(fields, h.fields...)
+		for _, g := range h.groups {
+			fields = append(fields, zap.Namespace(g))
+		}
+		r.Attrs(func(a slog.Attr) bool {
+			fields = appendAttr(fields, a)
+			return true
+		})
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	fields := make([]zap.Field, 0, len(h.fields)+len(h.groups)+len(attrs))
+	fields = append(fields, h.fields...)
+	for _, g := range h.groups {
+		fields = append(fields, zap.Namespace(g))
+	}
+	for _, a := range attrs {
+		fields = appendAttr(fields, a)
+	}
+	return &slogHandler{fields: fields}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &slogHandler{fields: h.fields, groups: append(groups, name)}
+}
+
+// zapLevel maps a slog level to the zap level it falls in.
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zap.DebugLevel
+	case level < slog.LevelWarn:
+		return zap.InfoLevel
+	case level < slog.LevelError:
+		return zap.WarnLevel
+	default:
+		return zap.ErrorLevel
+	}
+}
+
+// appendAttr appends a as a field, following the slog rules: empty attrs are
+// ignored and groups with an empty key are inlined.
+func appendAttr(fields []zap.Field, a slog.Attr) []zap.Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+	v := a.Value
+	switch v.Kind() {
+	case slog.KindGroup:
+		attrs := v.Group()
+		if len(attrs) == 0 {
+			return fields
+		}
+		if a.Key == "" {
+			for _, ga := range attrs {
+				fields = appendAttr(fields, ga)
+			}
+			return fields
+		}
+		return append(fields, zap.Object(a.Key, slogGroup(attrs)))
+	case slog.KindString:
+		return append(fields, zap.String(a.Key, v.String()))
+	case slog.KindInt64:
+		return append(fields, zap.Int64(a.Key, v.Int64()))
+	case slog.KindUint64:
+		return append(fields, zap.Uint64(a.Key, v.Uint64()))
+	case slog.KindFloat64:
+		return append(fields, zap.Float64(a.Key, v.Float64()))
+	case slog.KindBool:
+		return append(fields, zap.Bool(a.Key, v.Bool()))
+	case slog.KindDuration:
+		return append(fields, zap.Duration(a.Key, v.Duration()))
+	case slog.KindTime:
+		return append(fields, zap.Time(a.Key, v.Time()))
+	default:
+		if err, ok := v.Any().(error); ok {
+			return append(fields, zap.NamedError(a.Key, err))
+		}
+		return append(fields, zap.Any(a.Key, v.Any()))
+	}
+}
+
+// slogGroup encodes the attrs of a group as an object.
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	var fields []zap.Field
+	for _, a := range g {
+		fields = appendAttr(fields, a)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package log
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSlogHandler(t *testing.T) {
+	logger, logs := NewTestLogger(WithZapOptions(zap.AddCaller()))
+	defer ReplaceGlobal(logger)()
+	logger.Levels().SetLevel(zap.InfoLevel)
+
+	sl := slog.New(NewSlogHandler())
+	sl.Debug("hidden")
+	sl.With("service", "api").WithGroup("req").With("id", 7).WithGroup("empty").Warn("grouped",
+		slog.Group("user", slog.String("name", "bob")),
+		slog.Any("err", errors.New("boom")),
+	)
+	sl.WithGroup("unused").Info("no attrs")
+	ctx := AddFieldToCtx(context.Background(), zap.String("request_id", "42"))
+	sl.ErrorContext(ctx, "failed", slog.Group("", slog.Int("inline", 1)))
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 3)
+
+	assert.Equal(t, zap.WarnLevel, entries[0].Level)
+	assert.Equal(t, "grouped", entries[0].Message)
+	assert.Contains(t, entries[0].Caller.File, "slog_test.go")
+	assert.Equal(t, map[string]interface{}{
+		"service": "api",
+		"req": map[string]interface{}{
+			"id": int64(7),
+			"empty": map[string]interface{}{
+				"user": map[string]interface{}{"name": "bob"},
+				"err":  "boom",
+			},
+		},
+	}, entries[0].ContextMap())
+
+	assert.Equal(t, "no attrs", entries[1].Message)
+	assert.Empty(t, entries[1].ContextMap())
+
+	assert.Equal(t, zap.ErrorLevel, entries[2].Level)
+	assert.Equal(t, map[string]interface{}{"request_id": "42", "inline": int64(1)}, entries[2].ContextMap())
+}
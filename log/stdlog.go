@@ -0,0 +1,50 @@
+package log
+
+import (
+	"bytes"
+	stdlog "log"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// stdLogCallerSkip skips the stdlog frames between the caller of log.Printf
+// and stdLogWriter.Write.
+const stdLogCallerSkip = 3
+
+// RedirectStdLog redirects the output of the standard library log package
+// to the global logger at info level, see RedirectStdLogAt.
+func RedirectStdLog() func() {
+	return RedirectStdLogAt(zap.InfoLevel)
+}
+
+// RedirectStdLogAt redirects the output of the standard library log package
+// to the global logger at the given level, whichever logger is global at
+// the time of each write. It returns a function restoring the standard
+// logger's output, flags and prefix.
+func RedirectStdLogAt(level zapcore.Level) func() {
+	flags, prefix, out := stdlog.Flags(), stdlog.Prefix(), stdlog.Writer()
+	stdlog.SetFlags(0)
+	stdlog.SetPrefix("")
+	stdlog.SetOutput(stdLogWriter{level: level})
+	return func() {
+		stdlog.SetFlags(flags)
+		stdlog.SetPrefix(prefix)
+		stdlog.SetOutput(out)
+	}
+}
+
+// stdLogWriter writes each line of the standard logger as an entry of the
+// global logger.
+type stdLogWriter struct {
+	level zapcore.Level
+}
+
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimSuffix(p, []byte("\n")))
+	logger := L().zap.WithOptions(zap.AddCallerSkip(stdLogCallerSkip))
+	if ce := logger.Check(w.level, msg); ce != nil {
+		ce.Write()
+	}
+	return len(p), nil
+}
@@ -0,0 +1,26 @@
+package log
+
+import (
+	stdlog "log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestRedirectStdLog(t *testing.T) {
+	logger, logs := NewTestLogger(WithZapOptions(zap.AddCaller()))
+	defer ReplaceGlobal(logger)()
+
+	restore := RedirectStdLogAt(zap.WarnLevel)
+	stdlog.Printf("from %s", "stdlib")
+	restore()
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, zap.WarnLevel, entries[0].Level)
+	assert.Equal(t, "from stdlib", entries[0].Message)
+	assert.Contains(t, entries[0].Caller.File, "stdlog_test.go")
+	assert.NotEqual(t, 0, stdlog.Flags())
+}
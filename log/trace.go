@@ -0,0 +1,96 @@
+package log
+
+import (
+	"context"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const (
+	// TraceIDKey is the field key of the trace ID added by FromContext.
+	TraceIDKey = "trace_id"
+	// SpanIDKey is the field key of the span ID added by FromContext.
+	SpanIDKey = "span_id"
+)
+
+// traceNode returns node followed by the trace and span ID fields of the
+// span context in ctx, or node itself if there is none. The IDs override
+// fields of node with the same keys. The returned node is cached on node
+// per span, so that its logger is cached too.
+func traceNode(ctx context.Context, node *fieldNode) *fieldNode {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return node
+	}
+	if node == nil {
+		return &fieldNode{fields: spanFields(sc)}
+	}
+	if t, ok := node.traced.Load().(*tracedNode); ok && t.traceID == sc.TraceID() && t.spanID == sc.SpanID() {
+		return t.node
+	}
+	t := &tracedNode{
+		traceID: sc.TraceID(),
+		spanID:  sc.SpanID(),
+		node:    &fieldNode{parent: node, fields: spanFields(sc)},
+	}
+	node.traced.Store(t)
+	return t.node
+}
+
+type tracedNode struct {
+	traceID trace.TraceID
+	spanID  trace.SpanID
+	node    *fieldNode
+}
+
+func spanFields(sc trace.SpanContext) []zap.Field {
+	return []zap.Field{
+		zap.String(TraceIDKey, sc.TraceID().String()),
+		zap.String(SpanIDKey, sc.SpanID().String()),
+	}
+}
+
+// ParseTraceparent parses the value of a W3C traceparent header into a
+// remote span context.
+func ParseTraceparent(header string) (trace.SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[3]) != 2 {
+		return trace.SpanContext{}, errors.Errorf("log: malformed traceparent %q", header)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return trace.SpanContext{}, errors.Errorf("log: unsupported traceparent version in %q", header)
+	}
+	traceID, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return trace.SpanContext{}, errors.Wrapf(err, "log: traceparent %q", header)
+	}
+	spanID, err := trace.SpanIDFromHex(parts[2])
+	if err != nil {
+		return trace.SpanContext{}, errors.Wrapf(err, "log: traceparent %q", header)
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return trace.SpanContext{}, errors.Wrapf(err, "log: traceparent %q", header)
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.TraceFlags(flags[0]),
+		Remote:     true,
+	}), nil
+}
+
+// ContextWithTraceparent returns a copy of ctx carrying the remote span
+// context of a W3C traceparent header, for services that do not run an
+// OpenTelemetry propagator. Loggers from FromContext then carry its IDs.
+func ContextWithTraceparent(ctx context.Context, header string) (context.Context, error) {
+	sc, err := ParseTraceparent(header)
+	if err != nil {
+		return ctx, err
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc), nil
+}
@@ -0,0 +1,65 @@
+package log
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	sc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID().String())
+	assert.True(t, sc.IsSampled())
+	assert.True(t, sc.IsRemote())
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	} {
+		_, err := ParseTraceparent(header)
+		assert.Error(t, err, header)
+	}
+}
+
+func Test_FromContext_Trace(t *testing.T) {
+	logger, logs := NewTestLogger()
+	ctx, err := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+
+	ctx = logger.NewContext(ctx, zap.String("handler", "test"))
+	logger.FromContext(ctx).Info("traced")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, []zap.Field{
+		zap.String("handler", "test"),
+		zap.String(TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736"),
+		zap.String(SpanIDKey, "00f067aa0ba902b7"),
+	}, entries[0].Context)
+}
+
+func Test_FromContext_TraceOverrides(t *testing.T) {
+	logger, logs := NewTestLogger()
+	ctx, err := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.NoError(t, err)
+
+	ctx = AddFieldToCtx(ctx, zap.String(TraceIDKey, "stale"), zap.String("handler", "test"))
+	assert.Same(t, logger.FromContext(ctx), logger.FromContext(ctx))
+	logger.FromContext(ctx).Info("traced")
+
+	entries := logs.TakeAll()
+	require.Len(t, entries, 1)
+	assert.Equal(t, []zap.Field{
+		zap.String("handler", "test"),
+		zap.String(TraceIDKey, "4bf92f3577b34da6a3ce929d0e0e4736"),
+		zap.String(SpanIDKey, "00f067aa0ba902b7"),
+	}, entries[0].Context)
+}